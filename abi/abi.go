@@ -0,0 +1,334 @@
+package abi
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+//The contract ABIs in the solc JSON format: the functions and the events, with the types of their arguments.
+//The fixed point types are not supported, neither is any contract using them
+
+type ABI struct {
+	Functions []*Function
+	Events    []*Event
+}
+
+type Function struct {
+	Name            string
+	Inputs          []Argument
+	Outputs         []Argument
+	StateMutability string //pure, view, nonpayable or payable
+}
+
+type Event struct {
+	Name      string
+	Inputs    []Argument
+	Anonymous bool
+}
+
+type Argument struct {
+	Name    string
+	Type    *Type
+	Indexed bool //of the events
+}
+
+//An entry of the JSON ABI
+type entry struct {
+	Type            string      `json:"type"`
+	Name            string      `json:"name"`
+	Inputs          []jsonParam `json:"inputs"`
+	Outputs         []jsonParam `json:"outputs"`
+	StateMutability string      `json:"stateMutability"`
+	Constant        bool        `json:"constant"` //before solc 0.5
+	Payable         bool        `json:"payable"`
+	Anonymous       bool        `json:"anonymous"`
+}
+
+type jsonParam struct {
+	Name       string      `json:"name"`
+	Type       string      `json:"type"`
+	Components []jsonParam `json:"components"`
+	Indexed    bool        `json:"indexed"`
+}
+
+//Parses the JSON ABI. The constructor, the fallback and the errors are skipped
+func Parse(raw []byte) (*ABI, error) {
+	var entries []entry
+	if err := json.Unmarshal(raw, &entries); err != nil {
+		return nil, err
+	}
+	a := &ABI{}
+	for _, e := range entries {
+		inputs, err := arguments(e.Inputs)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", e.Name, err)
+		}
+		switch e.Type {
+		case "function", "":
+			outputs, err := arguments(e.Outputs)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", e.Name, err)
+			}
+			f := &Function{Name: e.Name, Inputs: inputs, Outputs: outputs, StateMutability: e.StateMutability}
+			if len(f.StateMutability) == 0 {
+				switch {
+				case e.Constant:
+					f.StateMutability = "view"
+				case e.Payable:
+					f.StateMutability = "payable"
+				default:
+					f.StateMutability = "nonpayable"
+				}
+			}
+			a.Functions = append(a.Functions, f)
+		case "event":
+			a.Events = append(a.Events, &Event{Name: e.Name, Inputs: inputs, Anonymous: e.Anonymous})
+		}
+	}
+	return a, nil
+}
+
+func arguments(params []jsonParam) ([]Argument, error) {
+	args := make([]Argument, len(params))
+	for i, p := range params {
+		t, err := parseType(p.Type, p.Components)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = Argument{Name: p.Name, Type: t, Indexed: p.Indexed}
+	}
+	return args, nil
+}
+
+//The canonical signature, e.g. transfer(address,uint256)
+func signature(name string, args []Argument) string {
+	types := make([]string, len(args))
+	for i, a := range args {
+		types[i] = a.Type.String()
+	}
+	return name + "(" + strings.Join(types, ",") + ")"
+}
+
+func (f *Function) Signature() string {
+	return signature(f.Name, f.Inputs)
+}
+
+//The first 4 bytes of the hash of the signature, which the call data starts with
+func (f *Function) Selector() []byte {
+	return Keccak256([]byte(f.Signature()))[:4]
+}
+
+//True for the functions which can be called with eth_call rather than sent as a transaction
+func (f *Function) IsReadOnly() bool {
+	return f.StateMutability == "view" || f.StateMutability == "pure"
+}
+
+func (f *Function) IsPayable() bool {
+	return f.StateMutability == "payable"
+}
+
+func (e *Event) Signature() string {
+	return signature(e.Name, e.Inputs)
+}
+
+//The hash of the signature, the first topic of the logs of the event unless it is anonymous
+func (e *Event) Topic() string {
+	return "0x" + hex.EncodeToString(Keccak256([]byte(e.Signature())))
+}
+
+//The function by its name or its signature. An overloaded name has to be given with the signature
+func (a *ABI) Function(name string) (*Function, error) {
+	var found *Function
+	for _, f := range a.Functions {
+		if f.Signature() == name {
+			return f, nil
+		}
+		if f.Name == name {
+			if found != nil {
+				return nil, errors.New("overloaded function, give the signature: " + name)
+			}
+			found = f
+		}
+	}
+	if found == nil {
+		return nil, errors.New("no such function: " + name)
+	}
+	return found, nil
+}
+
+//The function whose selector the call data starts with
+func (a *ABI) FunctionBySelector(data []byte) (*Function, bool) {
+	if len(data) < 4 {
+		return nil, false
+	}
+	for _, f := range a.Functions {
+		if string(f.Selector()) == string(data[:4]) {
+			return f, true
+		}
+	}
+	return nil, false
+}
+
+func (a *ABI) EventByTopic(topic string) (*Event, bool) {
+	for _, e := range a.Events {
+		if !e.Anonymous && strings.EqualFold(e.Topic(), topic) {
+			return e, true
+		}
+	}
+	return nil, false
+}
+
+//The kinds of the types
+const (
+	UintKind = iota
+	IntKind
+	AddressKind
+	BoolKind
+	FixedBytesKind
+	BytesKind
+	StringKind
+	SliceKind //T[]
+	ArrayKind //T[k]
+	TupleKind
+	FunctionKind //an address and a selector, 24 bytes
+)
+
+type Type struct {
+	Kind       int
+	Size       int //the bits of the integers, the bytes of bytesN and function, the length of T[k]
+	Elem       *Type
+	Components []Argument //of the tuples
+}
+
+func parseType(s string, components []jsonParam) (*Type, error) {
+	//The array suffixes are taken from the end: uint8[2][] is a slice of uint8[2]
+	if strings.HasSuffix(s, "]") {
+		open := strings.LastIndex(s, "[")
+		if open < 0 {
+			return nil, errors.New("invalid type " + s)
+		}
+		elem, err := parseType(s[:open], components)
+		if err != nil {
+			return nil, err
+		}
+		size := s[open+1 : len(s)-1]
+		if len(size) == 0 {
+			return &Type{Kind: SliceKind, Elem: elem}, nil
+		}
+		n, err := strconv.Atoi(size)
+		if err != nil || n <= 0 {
+			return nil, errors.New("invalid array size in " + s)
+		}
+		return &Type{Kind: ArrayKind, Size: n, Elem: elem}, nil
+	}
+	switch {
+	case s == "address":
+		return &Type{Kind: AddressKind, Size: 20}, nil
+	case s == "bool":
+		return &Type{Kind: BoolKind}, nil
+	case s == "string":
+		return &Type{Kind: StringKind}, nil
+	case s == "bytes":
+		return &Type{Kind: BytesKind}, nil
+	case s == "function":
+		return &Type{Kind: FunctionKind, Size: 24}, nil
+	case s == "tuple":
+		args, err := arguments(components)
+		if err != nil {
+			return nil, err
+		}
+		return &Type{Kind: TupleKind, Components: args}, nil
+	case strings.HasPrefix(s, "uint"), strings.HasPrefix(s, "int"):
+		kind, digits := UintKind, strings.TrimPrefix(s, "uint")
+		if !strings.HasPrefix(s, "uint") {
+			kind, digits = IntKind, strings.TrimPrefix(s, "int")
+		}
+		size := 256
+		if len(digits) > 0 {
+			var err error
+			if size, err = strconv.Atoi(digits); err != nil || size <= 0 || size > 256 || size%8 != 0 {
+				return nil, errors.New("invalid type " + s)
+			}
+		}
+		return &Type{Kind: kind, Size: size}, nil
+	case strings.HasPrefix(s, "bytes"):
+		size, err := strconv.Atoi(strings.TrimPrefix(s, "bytes"))
+		if err != nil || size <= 0 || size > 32 {
+			return nil, errors.New("invalid type " + s)
+		}
+		return &Type{Kind: FixedBytesKind, Size: size}, nil
+	}
+	return nil, errors.New("unsupported type " + s)
+}
+
+//The canonical name of the type, as in the signatures
+func (t *Type) String() string {
+	switch t.Kind {
+	case UintKind:
+		return "uint" + strconv.Itoa(t.Size)
+	case IntKind:
+		return "int" + strconv.Itoa(t.Size)
+	case AddressKind:
+		return "address"
+	case BoolKind:
+		return "bool"
+	case FixedBytesKind:
+		return "bytes" + strconv.Itoa(t.Size)
+	case BytesKind:
+		return "bytes"
+	case FunctionKind:
+		return "function"
+	case StringKind:
+		return "string"
+	case SliceKind:
+		return t.Elem.String() + "[]"
+	case ArrayKind:
+		return t.Elem.String() + "[" + strconv.Itoa(t.Size) + "]"
+	case TupleKind:
+		types := make([]string, len(t.Components))
+		for i, c := range t.Components {
+			types[i] = c.Type.String()
+		}
+		return "(" + strings.Join(types, ",") + ")"
+	}
+	return "?"
+}
+
+//The dynamic types are encoded after the static head, with their offset in it
+func (t *Type) isDynamic() bool {
+	switch t.Kind {
+	case BytesKind, StringKind, SliceKind:
+		return true
+	case ArrayKind:
+		return t.Elem.isDynamic()
+	case TupleKind:
+		for _, c := range t.Components {
+			if c.Type.isDynamic() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+//The size in the head: 32 bytes, or all the elements of a static array or tuple
+func (t *Type) headSize() int {
+	if t.isDynamic() {
+		return 32
+	}
+	switch t.Kind {
+	case ArrayKind:
+		return t.Size * t.Elem.headSize()
+	case TupleKind:
+		size := 0
+		for _, c := range t.Components {
+			size += c.Type.headSize()
+		}
+		return size
+	}
+	return 32
+}
@@ -0,0 +1,182 @@
+package abi
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+const erc20 = `[
+	{"type": "function", "name": "transfer", "stateMutability": "nonpayable",
+		"inputs": [{"name": "to", "type": "address"}, {"name": "amount", "type": "uint256"}], "outputs": [{"name": "", "type": "bool"}]},
+	{"type": "function", "name": "balanceOf", "stateMutability": "view",
+		"inputs": [{"name": "owner", "type": "address"}], "outputs": [{"name": "", "type": "uint256"}]},
+	{"type": "function", "name": "approve", "stateMutability": "nonpayable",
+		"inputs": [{"name": "spender", "type": "address"}, {"name": "amount", "type": "uint256"}], "outputs": [{"name": "", "type": "bool"}]},
+	{"type": "function", "name": "mixed", "stateMutability": "nonpayable",
+		"inputs": [{"name": "s", "type": "string"}, {"name": "xs", "type": "int16[]"}, {"name": "b", "type": "bytes"},
+			{"name": "pair", "type": "tuple", "components": [{"name": "ok", "type": "bool"}, {"name": "id", "type": "bytes4"}]},
+			{"name": "cb", "type": "function"}], "outputs": []},
+	{"type": "event", "name": "Transfer", "anonymous": false,
+		"inputs": [{"name": "from", "type": "address", "indexed": true}, {"name": "to", "type": "address", "indexed": true},
+			{"name": "value", "type": "uint256", "indexed": false}]},
+	{"type": "event", "name": "Approval", "anonymous": false,
+		"inputs": [{"name": "owner", "type": "address", "indexed": true}, {"name": "spender", "type": "address", "indexed": true},
+			{"name": "value", "type": "uint256", "indexed": false}]}
+]`
+
+func parsed(t *testing.T) *ABI {
+	a, err := Parse([]byte(erc20))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func TestKeccak256(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		hash string
+	}{
+		{"", "c5d2460186f7233c927e7db2dcc703c0e500b653ca82273b7bfad8045d85a470"},
+		{"abc", "4e03657aea45a94fc7d47ba826c8d667c0d1e6e33a64a036ec44f58fa12d6c45"},
+		{strings.Repeat("a", 136), "a6c4d403279fe3e0af03729caada8374b5ca54d8065329a3ebcaeb4b60aa386e"}, //a whole block, the padding in the next
+		{strings.Repeat("a", 300), "5b7e0e47a96f32a88b4f14ca177982790807c40e1a105742ba0fc1babe1ef826"},
+	} {
+		if got := hex.EncodeToString(Keccak256([]byte(tc.in))); got != tc.hash {
+			t.Errorf("Keccak256 of %d bytes: got %s, want %s", len(tc.in), got, tc.hash)
+		}
+	}
+	//The data given in parts hashes as a whole
+	if got := hex.EncodeToString(Keccak256([]byte("a"), []byte("bc"))); got != "4e03657aea45a94fc7d47ba826c8d667c0d1e6e33a64a036ec44f58fa12d6c45" {
+		t.Errorf("Keccak256 in parts: got %s", got)
+	}
+}
+
+func TestSelectors(t *testing.T) {
+	a := parsed(t)
+	for _, tc := range []struct {
+		name      string
+		signature string
+		selector  string
+	}{
+		{"transfer", "transfer(address,uint256)", "a9059cbb"},
+		{"balanceOf", "balanceOf(address)", "70a08231"},
+		{"approve", "approve(address,uint256)", "095ea7b3"},
+		{"mixed", "mixed(string,int16[],bytes,(bool,bytes4),function)", ""},
+	} {
+		f, err := a.Function(tc.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f.Signature() != tc.signature {
+			t.Errorf("signature of %s: got %s, want %s", tc.name, f.Signature(), tc.signature)
+		}
+		if len(tc.selector) > 0 && hex.EncodeToString(f.Selector()) != tc.selector {
+			t.Errorf("selector of %s: got %x, want %s", tc.name, f.Selector(), tc.selector)
+		}
+	}
+}
+
+func TestTopics(t *testing.T) {
+	a := parsed(t)
+	for _, tc := range []struct {
+		name  string
+		topic string
+	}{
+		{"Transfer", "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"},
+		{"Approval", "0x8c5be1e5ebec7d5bd14f71427d1e84f3dd0314c0f7b2291e5b200ac8c7c3b925"},
+	} {
+		e, ok := a.EventByTopic(tc.topic)
+		if !ok || e.Name != tc.name {
+			t.Errorf("no event %s by the topic %s", tc.name, tc.topic)
+			continue
+		}
+		if e.Topic() != tc.topic {
+			t.Errorf("topic of %s: got %s, want %s", tc.name, e.Topic(), tc.topic)
+		}
+	}
+}
+
+func TestEncodeCall(t *testing.T) {
+	f, _ := parsed(t).Function("transfer")
+	data, err := f.EncodeCall([]string{"0x5FbDB2315678afecb367f032d93F642f64180aa3", "1000"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "a9059cbb" +
+		"0000000000000000000000005fbdb2315678afecb367f032d93f642f64180aa3" +
+		"00000000000000000000000000000000000000000000000000000000000003e8"
+	if hex.EncodeToString(data) != want {
+		t.Errorf("got %x, want %s", data, want)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	a := parsed(t)
+	for _, tc := range []struct {
+		function string
+		args     []string
+		want     []string //as decoded, if other than the args
+	}{
+		{"transfer", []string{"0x5fbdb2315678afecb367f032d93f642f64180aa3", "0xff"}, []string{"0x5fbdb2315678afecb367f032d93f642f64180aa3", "255"}},
+		{"balanceOf", []string{"0x0000000000000000000000000000000000000001"}, nil},
+		{"mixed", []string{"hello, world", "[-1, 2, -32768]", "0x0102030405", "[true, \"0xdeadbeef\"]",
+			"0x5fbdb2315678afecb367f032d93f642f64180aa3a9059cbb"},
+			[]string{`"hello, world"`, "[-1, 2, -32768]", "0x0102030405", "(true, 0xdeadbeef)", "0x5fbdb2315678afecb367f032d93f642f64180aa3a9059cbb"}},
+		{"mixed", []string{"", "[]", "0x", "[false, \"0x00000000\"]", "0x000000000000000000000000000000000000000000000000"},
+			[]string{`""`, "[]", "0x", "(false, 0x00000000)", "0x000000000000000000000000000000000000000000000000"}},
+	} {
+		f, err := a.Function(tc.function)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := f.EncodeCall(tc.args)
+		if err != nil {
+			t.Errorf("%s%v: %v", tc.function, tc.args, err)
+			continue
+		}
+		values, err := f.DecodeInput(data)
+		if err != nil {
+			t.Errorf("%s%v: %v", tc.function, tc.args, err)
+			continue
+		}
+		want := tc.want
+		if want == nil {
+			want = tc.args
+		}
+		for i, v := range values {
+			if v.Value != want[i] {
+				t.Errorf("%s argument %d: got %s, want %s", tc.function, i, v.Value, want[i])
+			}
+		}
+	}
+}
+
+func TestDecodeLog(t *testing.T) {
+	e, _ := parsed(t).EventByTopic("0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef")
+	topics := []string{e.Topic(),
+		"0x0000000000000000000000005fbdb2315678afecb367f032d93f642f64180aa3",
+		"0x000000000000000000000000e7f1725e7734ce288f8367e1bb143e90bb3f0512"}
+	data, _ := DecodeHex("0x00000000000000000000000000000000000000000000000000000000000003e8")
+	values, err := e.DecodeLog(topics, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"0x5fbdb2315678afecb367f032d93f642f64180aa3", "0xe7f1725e7734ce288f8367e1bb143e90bb3f0512", "1000"}
+	for i, v := range values {
+		if v.Value != want[i] {
+			t.Errorf("%s: got %s, want %s", v.Name, v.Value, want[i])
+		}
+	}
+}
+
+func TestFunctionType(t *testing.T) {
+	typ, err := parseType("function", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if typ.String() != "function" || typ.Size != 24 || typ.isDynamic() {
+		t.Errorf("got %s of %d bytes", typ, typ.Size)
+	}
+}
@@ -0,0 +1,407 @@
+package abi
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+//The ABI encoding of the arguments and the results. The values come from the forms as text: the numbers in decimal
+//or 0x hex, the addresses and the bytes in hex, and the arrays and the tuples as JSON arrays, e.g. [1, "0xab", [true]].
+//The decoded values come back as text the same way
+
+//A decoded argument, result or log field
+type Value struct {
+	Name  string
+	Type  string
+	Value string
+}
+
+//Encodes the call data: the selector and the arguments
+func (f *Function) EncodeCall(args []string) ([]byte, error) {
+	if len(args) != len(f.Inputs) {
+		return nil, fmt.Errorf("%s takes %d arguments, got %d", f.Signature(), len(f.Inputs), len(args))
+	}
+	values := make([]interface{}, len(args))
+	for i := range args {
+		values[i] = args[i]
+	}
+	enc, err := encodeTuple(argTypes(f.Inputs), values)
+	if err != nil {
+		return nil, err
+	}
+	return append(f.Selector(), enc...), nil
+}
+
+//Decodes the arguments of the call data, which starts with the selector
+func (f *Function) DecodeInput(data []byte) ([]Value, error) {
+	if len(data) < 4 || !bytes.Equal(data[:4], f.Selector()) {
+		return nil, errors.New("not a call of " + f.Signature())
+	}
+	return decodeArguments(f.Inputs, data[4:])
+}
+
+//Decodes what the eth_call of the function returned
+func (f *Function) DecodeOutput(data []byte) ([]Value, error) {
+	return decodeArguments(f.Outputs, data)
+}
+
+//Decodes the log of the event: the indexed arguments from the topics, the others from the data.
+//The indexed arguments of the dynamic types are only there as the hash of their value
+func (e *Event) DecodeLog(topics []string, data []byte) ([]Value, error) {
+	if !e.Anonymous {
+		if len(topics) == 0 || !strings.EqualFold(topics[0], e.Topic()) {
+			return nil, errors.New("not a log of " + e.Signature())
+		}
+		topics = topics[1:]
+	}
+	var plain []Argument
+	for _, a := range e.Inputs {
+		if !a.Indexed {
+			plain = append(plain, a)
+		}
+	}
+	decoded, err := decodeArguments(plain, data)
+	if err != nil {
+		return nil, err
+	}
+	values := make([]Value, 0, len(e.Inputs))
+	for _, a := range e.Inputs {
+		if !a.Indexed {
+			values = append(values, decoded[0])
+			decoded = decoded[1:]
+			continue
+		}
+		if len(topics) == 0 {
+			return nil, errors.New("missing topic of " + a.Name)
+		}
+		topic, err := DecodeHex(topics[0])
+		topics = topics[1:]
+		if err != nil || len(topic) != 32 {
+			return nil, errors.New("invalid topic of " + a.Name)
+		}
+		v := Value{Name: a.Name, Type: a.Type.String()}
+		if a.Type.isDynamic() || a.Type.Kind == ArrayKind || a.Type.Kind == TupleKind {
+			v.Value = "hash 0x" + hex.EncodeToString(topic)
+		} else if v.Value, err = decode(a.Type, topic); err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+//The bytes of the 0x prefixed hex string
+func DecodeHex(s string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "0x") && !strings.HasPrefix(s, "0X") {
+		return nil, errors.New("not a hex string: " + s)
+	}
+	s = s[2:]
+	if len(s)%2 == 1 {
+		s = "0" + s
+	}
+	return hex.DecodeString(s)
+}
+
+func argTypes(args []Argument) []*Type {
+	types := make([]*Type, len(args))
+	for i, a := range args {
+		types[i] = a.Type
+	}
+	return types
+}
+
+func decodeArguments(args []Argument, data []byte) ([]Value, error) {
+	texts, err := decodeTuple(argTypes(args), data)
+	if err != nil {
+		return nil, err
+	}
+	values := make([]Value, len(args))
+	for i, a := range args {
+		values[i] = Value{Name: a.Name, Type: a.Type.String(), Value: texts[i]}
+	}
+	return values, nil
+}
+
+//Encoding
+
+func encodeTuple(types []*Type, values []interface{}) ([]byte, error) {
+	if len(types) != len(values) {
+		return nil, fmt.Errorf("expected %d values, got %d", len(types), len(values))
+	}
+	headSize := 0
+	for _, t := range types {
+		headSize += t.headSize()
+	}
+	var head, tail []byte
+	for i, t := range types {
+		enc, err := encode(t, values[i])
+		if err != nil {
+			return nil, err
+		}
+		if t.isDynamic() {
+			head = append(head, word(big.NewInt(int64(headSize+len(tail))))...)
+			tail = append(tail, enc...)
+		} else {
+			head = append(head, enc...)
+		}
+	}
+	return append(head, tail...), nil
+}
+
+//The value is the text of the form, or an element of the JSON array the text of an array or a tuple was
+func encode(t *Type, v interface{}) ([]byte, error) {
+	switch t.Kind {
+	case SliceKind, ArrayKind, TupleKind:
+		items, err := asList(v)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", t, err)
+		}
+		switch t.Kind {
+		case SliceKind:
+			enc, err := encodeTuple(repeat(t.Elem, len(items)), items)
+			if err != nil {
+				return nil, err
+			}
+			return append(word(big.NewInt(int64(len(items)))), enc...), nil
+		case ArrayKind:
+			if len(items) != t.Size {
+				return nil, fmt.Errorf("%s takes %d values, got %d", t, t.Size, len(items))
+			}
+			return encodeTuple(repeat(t.Elem, t.Size), items)
+		}
+		return encodeTuple(argTypes(t.Components), items)
+	}
+	s, err := asText(v)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", t, err)
+	}
+	switch t.Kind {
+	case UintKind, IntKind:
+		n, ok := parseInt(s)
+		if !ok {
+			return nil, fmt.Errorf("%s: not a number: %s", t, s)
+		}
+		limit := new(big.Int).Lsh(big.NewInt(1), uint(t.Size))
+		if t.Kind == IntKind {
+			limit.Rsh(limit, 1)
+			if n.Cmp(limit) >= 0 || n.Cmp(new(big.Int).Neg(limit)) < 0 {
+				return nil, fmt.Errorf("%s: out of range: %s", t, s)
+			}
+			if n.Sign() < 0 {
+				n.Add(n, new(big.Int).Lsh(big.NewInt(1), 256))
+			}
+			return word(n), nil
+		}
+		if n.Sign() < 0 || n.Cmp(limit) >= 0 {
+			return nil, fmt.Errorf("%s: out of range: %s", t, s)
+		}
+		return word(n), nil
+	case AddressKind:
+		b, err := DecodeHex(s)
+		if err != nil || len(b) != 20 {
+			return nil, errors.New("not an address: " + s)
+		}
+		return leftPad(b), nil
+	case BoolKind:
+		switch strings.ToLower(s) {
+		case "true", "1":
+			return word(big.NewInt(1)), nil
+		case "false", "0":
+			return word(big.NewInt(0)), nil
+		}
+		return nil, errors.New("not a bool: " + s)
+	case FixedBytesKind, FunctionKind:
+		b, err := DecodeHex(s)
+		if err != nil || len(b) > t.Size {
+			return nil, fmt.Errorf("%s: not a hex string of %d bytes: %s", t, t.Size, s)
+		}
+		return rightPad(b), nil
+	case BytesKind:
+		b, err := DecodeHex(s)
+		if err != nil {
+			return nil, err
+		}
+		return append(word(big.NewInt(int64(len(b)))), rightPad(b)...), nil
+	case StringKind:
+		return append(word(big.NewInt(int64(len(s)))), rightPad([]byte(s))...), nil
+	}
+	return nil, errors.New("cannot encode " + t.String())
+}
+
+func repeat(t *Type, n int) []*Type {
+	types := make([]*Type, n)
+	for i := range types {
+		types[i] = t
+	}
+	return types
+}
+
+//The elements of an array or a tuple, given either as the JSON text or as the already parsed array
+func asList(v interface{}) ([]interface{}, error) {
+	if items, ok := v.([]interface{}); ok {
+		return items, nil
+	}
+	s, ok := v.(string)
+	if !ok {
+		return nil, fmt.Errorf("not an array: %v", v)
+	}
+	dec := json.NewDecoder(strings.NewReader(s))
+	dec.UseNumber()
+	var items []interface{}
+	if err := dec.Decode(&items); err != nil {
+		return nil, errors.New("not a JSON array: " + s)
+	}
+	return items, nil
+}
+
+func asText(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return strings.TrimSpace(v), nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	}
+	return "", fmt.Errorf("not a single value: %v", v)
+}
+
+//Parses a decimal or a 0x hex integer
+func parseInt(s string) (*big.Int, bool) {
+	neg := strings.HasPrefix(s, "-")
+	digits := strings.TrimPrefix(s, "-")
+	base := 10
+	if strings.HasPrefix(digits, "0x") || strings.HasPrefix(digits, "0X") {
+		digits, base = digits[2:], 16
+	}
+	n, ok := new(big.Int).SetString(digits, base)
+	if ok && neg {
+		n.Neg(n)
+	}
+	return n, ok
+}
+
+//The 32 byte big endian word of a non-negative number
+func word(n *big.Int) []byte {
+	w := make([]byte, 32)
+	return n.FillBytes(w)
+}
+
+func leftPad(b []byte) []byte {
+	w := make([]byte, 32)
+	copy(w[32-len(b):], b)
+	return w
+}
+
+func rightPad(b []byte) []byte {
+	w := make([]byte, (len(b)+31)/32*32)
+	copy(w, b)
+	return w
+}
+
+//Decoding
+
+func decodeTuple(types []*Type, data []byte) ([]string, error) {
+	values := make([]string, len(types))
+	pos := 0
+	for i, t := range types {
+		var err error
+		if t.isDynamic() {
+			offset, oerr := readLength(data, pos)
+			if oerr != nil {
+				return nil, oerr
+			}
+			values[i], err = decode(t, data[offset:])
+		} else {
+			if pos+t.headSize() > len(data) {
+				return nil, errors.New("data too short for " + t.String())
+			}
+			values[i], err = decode(t, data[pos:])
+		}
+		if err != nil {
+			return nil, err
+		}
+		pos += t.headSize()
+	}
+	return values, nil
+}
+
+//Reads a length or an offset word, which has to point within the data
+func readLength(data []byte, pos int) (int, error) {
+	if pos+32 > len(data) {
+		return 0, errors.New("data too short")
+	}
+	n := new(big.Int).SetBytes(data[pos : pos+32])
+	if !n.IsInt64() || n.Int64() > int64(len(data)) {
+		return 0, errors.New("invalid offset or length")
+	}
+	return int(n.Int64()), nil
+}
+
+//Decodes the value at the start of the data
+func decode(t *Type, data []byte) (string, error) {
+	switch t.Kind {
+	case SliceKind:
+		n, err := readLength(data, 0)
+		if err != nil {
+			return "", err
+		}
+		items, err := decodeTuple(repeat(t.Elem, n), data[32:])
+		if err != nil {
+			return "", err
+		}
+		return "[" + strings.Join(items, ", ") + "]", nil
+	case ArrayKind:
+		items, err := decodeTuple(repeat(t.Elem, t.Size), data)
+		if err != nil {
+			return "", err
+		}
+		return "[" + strings.Join(items, ", ") + "]", nil
+	case TupleKind:
+		items, err := decodeTuple(argTypes(t.Components), data)
+		if err != nil {
+			return "", err
+		}
+		return "(" + strings.Join(items, ", ") + ")", nil
+	case BytesKind, StringKind:
+		n, err := readLength(data, 0)
+		if err != nil {
+			return "", err
+		}
+		if 32+n > len(data) {
+			return "", errors.New("data too short for " + t.String())
+		}
+		if t.Kind == StringKind {
+			return strconv.Quote(string(data[32 : 32+n])), nil
+		}
+		return "0x" + hex.EncodeToString(data[32:32+n]), nil
+	}
+	if len(data) < 32 {
+		return "", errors.New("data too short for " + t.String())
+	}
+	w := data[:32]
+	switch t.Kind {
+	case UintKind:
+		return new(big.Int).SetBytes(w).String(), nil
+	case IntKind:
+		n := new(big.Int).SetBytes(w)
+		if w[0]&0x80 != 0 {
+			n.Sub(n, new(big.Int).Lsh(big.NewInt(1), 256))
+		}
+		return n.String(), nil
+	case AddressKind:
+		return "0x" + hex.EncodeToString(w[12:]), nil
+	case BoolKind:
+		return strconv.FormatBool(w[31] != 0), nil
+	case FixedBytesKind, FunctionKind:
+		return "0x" + hex.EncodeToString(w[:t.Size]), nil
+	}
+	return "", errors.New("cannot decode " + t.String())
+}
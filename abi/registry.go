@@ -0,0 +1,241 @@
+package abi
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+//The ABIs the operators have uploaded, keyed by the contract address and kept in a json file:
+//	{"0x5fbdb2315678afecb367f032d93f642f64180aa3": {"name": "Token", "abi": [...]}}
+//The calls and the logs of the known contracts are decoded with their ABI. Those of the other addresses
+//with the first ABI that matches the selector or the event topic, as many contracts share the standard interfaces
+
+const DefaultRegistryFile = "abi.registry.json"
+
+var address = regexp.MustCompile("^0x[0-9a-fA-F]{40}$")
+
+var errNoRegistry = errors.New("no ABI registry is kept, see the -abiFile flag")
+
+type Contract struct {
+	Address string          `json:"-"`
+	Name    string          `json:"name"`
+	ABI     json.RawMessage `json:"abi"`
+	parsed  *ABI
+}
+
+func (c *Contract) Parsed() *ABI {
+	return c.parsed
+}
+
+type Registry struct {
+	file      string
+	contracts map[string]*Contract //by the lowercase address
+	readOnly  error                //why the file is not to be written over, nil if it can be
+	mx        sync.RWMutex
+}
+
+//Reads the registry from the file, an empty one if there is no file yet.
+//If the file cannot be read as a whole, the registry decodes with what could be read but is not saved,
+//so that the ABIs of the file are not lost before the operator fixes it
+func OpenRegistry(file string) (*Registry, error) {
+	r := &Registry{file: file, contracts: map[string]*Contract{}}
+	buff, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return r, nil
+	}
+	if err == nil {
+		err = json.Unmarshal(buff, &r.contracts)
+	}
+	if err != nil {
+		r.contracts = map[string]*Contract{}
+		r.readOnly = errors.New(file + " could not be read, fix it before changing the registry: " + err.Error())
+		return r, r.readOnly
+	}
+	//A broken ABI is left out, not the others
+	for addr, c := range r.contracts {
+		c.Address = addr
+		var perr error
+		if c.parsed, perr = Parse(c.ABI); perr != nil {
+			delete(r.contracts, addr)
+			r.readOnly = errors.New("the ABI of " + addr + " in " + file + " is broken, fix it before changing the registry: " + perr.Error())
+		}
+	}
+	return r, r.readOnly
+}
+
+//Adds or replaces the ABI of the contract and saves the registry
+func (r *Registry) Add(addr, name string, raw []byte) error {
+	if r == nil {
+		return errNoRegistry
+	}
+	addr = strings.ToLower(strings.TrimSpace(addr))
+	if !address.MatchString(addr) {
+		return errors.New("not a valid address: " + addr)
+	}
+	parsed, err := Parse(raw)
+	if err != nil {
+		return err
+	}
+	if len(name) == 0 {
+		name = addr
+	}
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	if r.readOnly != nil {
+		return r.readOnly
+	}
+	r.contracts[addr] = &Contract{Address: addr, Name: name, ABI: json.RawMessage(raw), parsed: parsed}
+	return r.save()
+}
+
+func (r *Registry) Remove(addr string) error {
+	if r == nil {
+		return errNoRegistry
+	}
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	if r.readOnly != nil {
+		return r.readOnly
+	}
+	delete(r.contracts, strings.ToLower(strings.TrimSpace(addr)))
+	return r.save()
+}
+
+//Why the registry cannot be changed, nil if it can
+func (r *Registry) ReadOnly() error {
+	if r == nil {
+		return errNoRegistry
+	}
+	return r.readOnly
+}
+
+//Expects the lock held
+func (r *Registry) save() error {
+	bytes, err := json.MarshalIndent(r.contracts, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(r.file, bytes, 0644)
+}
+
+//The contract at the address, nil if its ABI is not known
+func (r *Registry) Contract(addr string) *Contract {
+	if r == nil {
+		return nil
+	}
+	r.mx.RLock()
+	defer r.mx.RUnlock()
+	return r.contracts[strings.ToLower(strings.TrimSpace(addr))]
+}
+
+//The contracts by their name
+func (r *Registry) Contracts() []*Contract {
+	if r == nil {
+		return nil
+	}
+	r.mx.RLock()
+	list := make([]*Contract, 0, len(r.contracts))
+	for _, c := range r.contracts {
+		list = append(list, c)
+	}
+	r.mx.RUnlock()
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Name != list[j].Name {
+			return list[i].Name < list[j].Name
+		}
+		return list[i].Address < list[j].Address
+	})
+	return list
+}
+
+//The contract at the address first, then the others
+func (r *Registry) candidates(addr string) []*Contract {
+	list := r.Contracts()
+	addr = strings.ToLower(addr)
+	for i, c := range list {
+		if c.Address == addr {
+			list[0], list[i] = list[i], list[0]
+			break
+		}
+	}
+	return list
+}
+
+//A decoded call: the arguments and, of an eth_call, the results
+type DecodedCall struct {
+	Contract string //the name of the contract whose ABI decoded it
+	Function *Function
+	Inputs   []Value
+	Outputs  []Value
+	Error    string //if the results could not be decoded, e.g. of a reverted call
+}
+
+type DecodedLog struct {
+	Contract string
+	Event    *Event
+	Values   []Value
+}
+
+//Decodes the call data of a transaction or an eth_call to the address, nil if no ABI matches it
+func (r *Registry) DecodeCall(to, input string) *DecodedCall {
+	if r == nil {
+		return nil
+	}
+	data, err := DecodeHex(input)
+	if err != nil || len(data) < 4 {
+		return nil
+	}
+	for _, c := range r.candidates(to) {
+		f, ok := c.parsed.FunctionBySelector(data)
+		if !ok {
+			continue
+		}
+		if inputs, err := f.DecodeInput(data); err == nil {
+			return &DecodedCall{Contract: c.Name, Function: f, Inputs: inputs}
+		}
+	}
+	return nil
+}
+
+//Decodes the call data and what the eth_call returned
+func (r *Registry) DecodeResult(to, input, output string) *DecodedCall {
+	dc := r.DecodeCall(to, input)
+	if dc == nil {
+		return nil
+	}
+	data, err := DecodeHex(output)
+	if err == nil {
+		dc.Outputs, err = dc.Function.DecodeOutput(data)
+	}
+	if err != nil {
+		dc.Error = err.Error()
+	}
+	return dc
+}
+
+//Decodes the log of the address, nil if no ABI matches it
+func (r *Registry) DecodeLog(addr string, topics []string, data string) *DecodedLog {
+	if r == nil || len(topics) == 0 {
+		return nil
+	}
+	raw, err := DecodeHex(data)
+	if err != nil {
+		return nil
+	}
+	for _, c := range r.candidates(addr) {
+		e, ok := c.parsed.EventByTopic(topics[0])
+		if !ok {
+			continue
+		}
+		if values, err := e.DecodeLog(topics, raw); err == nil {
+			return &DecodedLog{Contract: c.Name, Event: e, Values: values}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package abi
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+const tokenAddress = "0x5fbdb2315678afecb367f032d93f642f64180aa3"
+
+func TestRegistrySaved(t *testing.T) {
+	file := filepath.Join(t.TempDir(), DefaultRegistryFile)
+	r, err := OpenRegistry(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = r.Add(tokenAddress, "Token", []byte(erc20)); err != nil {
+		t.Fatal(err)
+	}
+	r, err = OpenRegistry(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := r.Contract(tokenAddress)
+	if c == nil || c.Name != "Token" || len(c.Parsed().Functions) != 4 {
+		t.Fatalf("the contract was not read back: %+v", c)
+	}
+	dc := r.DecodeCall("0x0000000000000000000000000000000000000001",
+		"0xa9059cbb0000000000000000000000005fbdb2315678afecb367f032d93f642f64180aa300000000000000000000000000000000000000000000000000000000000003e8")
+	if dc == nil || dc.Function.Name != "transfer" || dc.Inputs[1].Value != "1000" {
+		t.Errorf("the call to another address was not decoded by the selector: %+v", dc)
+	}
+}
+
+//A file which cannot be read is not written over
+func TestRegistryBrokenFile(t *testing.T) {
+	for _, content := range []string{
+		`{"` + tokenAddress + `": {"name": "Token", "abi": [` + "\n",
+		`{"` + tokenAddress + `": {"name": "Token", "abi": [{"type": "function", "name": "f", "inputs": [{"type": "fixed128x18"}]}]}}`,
+	} {
+		file := filepath.Join(t.TempDir(), DefaultRegistryFile)
+		if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		r, err := OpenRegistry(file)
+		if err == nil || r.ReadOnly() == nil {
+			t.Errorf("no error reading %s", content)
+		}
+		if err = r.Add("0x0000000000000000000000000000000000000001", "Other", []byte(erc20)); err == nil {
+			t.Errorf("added to the registry read from %s", content)
+		}
+		if err = r.Remove(tokenAddress); err == nil {
+			t.Errorf("removed from the registry read from %s", content)
+		}
+		if kept, _ := ioutil.ReadFile(file); string(kept) != content {
+			t.Errorf("the file was written over: %s", kept)
+		}
+	}
+}
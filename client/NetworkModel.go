@@ -1,6 +1,8 @@
 package client
 
 import (
+	"encoding/hex"
+	"github.com/san-lab/toolsmith/abi"
 	"log"
 	"strings"
 	"time"
@@ -13,21 +15,43 @@ type BlockchainNet struct {
 	AccessNodeID      NodeID
 	NetworkID         string
 	Nodes             map[NodeID]*Node //The NodeID is meant to be the key here
-
+	graph             *graphCache      //of this version of the model
 }
 
 func NewBlockchainNet() *BlockchainNet {
 	bl := &BlockchainNet{}
 	bl.Nodes = map[NodeID]*Node{}
+	bl.graph = &graphCache{}
 	return bl
 }
 
+//A working copy of the model for a probe to modify.
+//The peer stubs are shared, as they are never modified once created
+func (bcn *BlockchainNet) clone() *BlockchainNet {
+	cp := *bcn
+	cp.graph = &graphCache{}
+	cp.Nodes = make(map[NodeID]*Node, len(bcn.Nodes))
+	for id, n := range bcn.Nodes {
+		cp.Nodes[id] = n.clone()
+	}
+	return &cp
+}
+
 type MyTime time.Time
 
 func (mt MyTime) String() string {
 	return time.Time(mt).Format(time.RFC1123)
 }
 
+//In the RFC 3339 format, as the time.Time
+func (mt MyTime) MarshalJSON() ([]byte, error) {
+	return time.Time(mt).MarshalJSON()
+}
+
+func (mt *MyTime) UnmarshalJSON(raw []byte) error {
+	return (*time.Time)(mt).UnmarshalJSON(raw)
+}
+
 type NodeStatus string
 
 const Unknown NodeStatus = "unknown"
@@ -51,6 +75,7 @@ type Node struct {
 	LastBlockNumberSample *BlockNumberSample
 	PrevBlockNumberSample *BlockNumberSample
 	TxpoolStatus          *TxpoolStatusSample
+	TxpoolError           string           // why the txpool status could not be read, if it could not
 	Peers                 map[NodeID]*Node //
 	LastReach             MyTime
 	LastFail              MyTime
@@ -59,7 +84,13 @@ type Node struct {
 	progress      bool
 	ClientVersion string
 	isFromPeer    bool
-	RPCAddress    string // hostname:port
+	RPCAddress    string      // hostname:port
+	SyncStatus    *SyncStatus // eth_syncing, if the client has been asked
+	Clique        *CliqueInfo // nil unless the node runs clique
+	BFT           *BFTInfo    // nil unless the node runs IBFT/QBFT
+	consensus     string      // the consensus api the node answers to, "" if not known yet
+	heads         []BlockRef  // the last blocks of the node's chain, oldest first
+	Reorgs        []Reorg     // the latest reorgs seen on the node, oldest first
 }
 
 func (n *Node) IsStuck() bool {
@@ -85,19 +116,19 @@ func (n *Node) PeerSeenAs(peer NodeID) (string, bool) {
 	return "", false
 }
 
-// Geth specific
-// Extract the short name from the NodeAddress name
-// assuming the name is of the form: "Geth/miner3/v1.7.2-stable/linux-amd64/go1.9.2"
-func (n Node) getGethShortName() (string, bool) {
-	if !n.IsGeth() {
-		return "", false
-	}
+// Besu, Erigon and Nethermind
+// The name is "besu/v21.1.0/linux-x86_64/openjdk-java-11", or with the --identity:
+// "besu/validator1/v21.1.0/linux-x86_64/openjdk-java-11"
+// Without the identity the node is named by its IP
+func (n Node) getIdentityShortName(ip string) string {
 	parts := strings.Split(n.FullName, "/")
-	if len(parts) > 1 {
-		return parts[1], true
-
+	if len(parts) > 4 {
+		return parts[1]
 	}
-	return "unknown", false
+	if len(ip) > 0 {
+		return parts[0] + "@" + ip
+	}
+	return parts[0] + "-" + n.IDHead(7)
 }
 
 //This is a stub. The address does not include the rpc port
@@ -129,6 +160,14 @@ func (n Node) IDTail(i int) string {
 	return string(n.ID)[len(n.ID)-i:]
 }
 
+//The last block number seen, 0 if none
+func (n *Node) blockNumber() HexString {
+	if n.LastBlockNumberSample == nil {
+		return 0
+	}
+	return n.LastBlockNumberSample.BlockNumber
+}
+
 func (n *Node) setReachable(is bool) {
 	log.Printf("Setting %s as reachable=%v\n", n.ShortName, is)
 	n.issReachable = is
@@ -143,22 +182,32 @@ func (n *Node) IsReachable() bool {
 	return n.issReachable
 }
 
-func (n *Node) IsParity() bool {
-	return strings.HasPrefix(n.ClientVersion, "Parity")
-}
-
-func (n *Node) IsGeth() bool {
-	return strings.HasPrefix(n.ClientVersion, "Geth")
-}
-
-func (n *Node) IsPantheon() bool {
-	return strings.HasPrefix(n.ClientVersion, "pantheon")
+//The method listing the transactions in the pool, for the views to link to
+func (n *Node) TxpoolMethod() string {
+	c, err := n.collector()
+	if err != nil {
+		return "txpool_inspect"
+	}
+	return c.TxpoolMethod()
 }
 
 func (n *Node) ClientType() string {
 	return strings.Split(n.ClientVersion, "/")[0]
 }
 
+func (n *Node) clone() *Node {
+	cp := *n
+	cp.KnownAddresses = make(map[string]bool, len(n.KnownAddresses))
+	for a, ok := range n.KnownAddresses {
+		cp.KnownAddresses[a] = ok
+	}
+	cp.Peers = make(map[NodeID]*Node, len(n.Peers))
+	for id, p := range n.Peers {
+		cp.Peers[id] = p
+	}
+	return &cp
+}
+
 func NewNode() *Node {
 	n := &Node{}
 	n.KnownAddresses = map[string]bool{}
@@ -167,22 +216,33 @@ func NewNode() *Node {
 	return n
 }
 
-//Geth specific
-func FillNodeFromNodeInfo_Geth(n *Node, ni *NodeInfo) {
-	n.ID = NodeID(ni.ID)
-	n.ThisNodeInfo = *ni
-	n.FullName = ni.Name
-	n.Enode = ni.Enode
-	n.ShortName, _ = n.getGethShortName()
-	n.setReachable(true) //This is based on the assumption that the node info has been just obtained
-	return
+//The clients do not agree on the node ids: the newer geths report the keccak hash of the public key, the older geths
+//and Besu (with 0x) the key itself, and the older geths do not report the enode urls of their peers.
+//The id is always taken as the hash of the key, as the newer geths have it, so that the nodes and the peers match
+func nodeID(id string, enode string) NodeID {
+	key := enodeKey(enode)
+	if len(key) == 0 {
+		key = strings.ToLower(strings.TrimPrefix(id, "0x"))
+	}
+	if pub, err := hex.DecodeString(key); err == nil && len(pub) == 64 {
+		return NodeID(hex.EncodeToString(abi.Keccak256(pub)))
+	}
+	return NodeID(key)
+}
+
+//The public key in an enode url, "" if there is none
+func enodeKey(enode string) string {
+	if !strings.HasPrefix(enode, "enode://") {
+		return ""
+	}
+	return strings.ToLower(strings.Split(strings.TrimPrefix(enode, "enode://"), "@")[0])
 }
 
 func NodeFromPeerInfo_Geth(n *Node, pi *PeerInfo) *Node {
 	if n == nil {
 		n = NewNode()
 	}
-	n.ID = NodeID(pi.ID)
+	n.ID = nodeID(pi.ID, pi.Enode)
 	n.FullName = pi.Name
 	n.ShortName, _ = n.getGethShortName()
 	addr := strings.Split(pi.Network.RemoteAddress, ":")[0]
@@ -194,7 +254,7 @@ func NodeFromPeerInfo_Geth(n *Node, pi *PeerInfo) *Node {
 func (bcn *BlockchainNet) isOk() bool {
 	for k, v := range bcn.Nodes {
 		if k != v.ID {
-			log.Fatal("false key %s for node %s\n", k, v.ID)
+			log.Fatalf("false key %s for node %s\n", k, v.ID)
 			return false
 		}
 	}
@@ -0,0 +1,146 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+//JSON-RPC batches: several commands to the same endpoint in a single request.
+//Not every node (or every proxy in front of a node) accepts batches, so the client remembers
+//the endpoints which have rejected one and talks to them one call at a time, for a while:
+//the node may be upgraded or the proxy reconfigured, so the batches are tried again after the noBatchRetry.
+
+var ErrBatchNotSupported = errors.New("batch requests not supported by the endpoint")
+
+const noBatchRetry = 30 * time.Minute
+
+//Sends all the calls in one JSON-RPC batch. All the calls have to target the same endpoint.
+//The responses are matched back by the "id" and decoded into the corresponding CallData.
+//The returned slice holds the per-call decoding errors; the error is returned if the batch as a whole failed.
+func (rpcClient *Client) BatchRPC(calls []*CallData) ([]error, error) {
+	if len(calls) == 0 {
+		return nil, nil
+	}
+	endpoint := calls[0].Context.TargetRPCEndpoint
+	commands := make([]EthCommand, len(calls))
+	for i, data := range calls {
+		if data.Context.TargetRPCEndpoint != endpoint {
+			return nil, errors.New("a batch has to target a single endpoint")
+		}
+		data.Command.Id = rpcClient.nextID()
+		commands[i] = data.Command
+	}
+	if rpcClient.isBlocked(endpoint) {
+		return nil, errors.New("Blocked address:" + endpoint)
+	}
+	jcom, _ := json.Marshal(commands)
+	respBytes, err := rpcClient.post(endpoint, jcom)
+	if err != nil {
+		//A proxy refuses what it does not understand as a bad request or a wrong method.
+		//The other statuses are of a failing node, which would fail the single calls as well
+		if se, isStatus := err.(httpStatusError); isStatus && (se.code == http.StatusBadRequest || se.code == http.StatusMethodNotAllowed) {
+			return nil, ErrBatchNotSupported
+		}
+		return nil, err
+	}
+	//A node not supporting batches answers with a single error object
+	if trimmed := bytes.TrimSpace(respBytes); len(trimmed) == 0 || trimmed[0] != '[' {
+		return nil, ErrBatchNotSupported
+	}
+	var rawResponses []json.RawMessage
+	err = json.Unmarshal(respBytes, &rawResponses)
+	if err != nil || len(rawResponses) == 0 {
+		return nil, ErrBatchNotSupported
+	}
+	byID := map[uint]json.RawMessage{}
+	for _, raw := range rawResponses {
+		var head struct {
+			ID *uint `json:"id"`
+		}
+		if json.Unmarshal(raw, &head) == nil && head.ID != nil {
+			byID[*head.ID] = raw
+		}
+	}
+	errs := make([]error, len(calls))
+	for i, data := range calls {
+		jreq, _ := json.Marshal(data.Command)
+		data.JsonRequest = string(jreq)
+		raw, ok := byID[data.Command.Id]
+		if !ok {
+			errs[i] = fmt.Errorf("no response for %s (id %v) in the batch", data.Command.Method, data.Command.Id)
+			continue
+		}
+		errs[i] = rpcClient.processResponse(data, raw)
+	}
+	return errs, nil
+}
+
+//Executes the calls to the same endpoint, as a batch if the endpoint is not known to reject them,
+//otherwise one by one. Returns the per-call errors.
+func (rpcClient *Client) callAll(calls ...*CallData) []error {
+	if len(calls) == 0 {
+		return nil
+	}
+	endpoint := calls[0].Context.TargetRPCEndpoint
+	if len(calls) > 1 && rpcClient.mayBatch(endpoint) {
+		errs, err := rpcClient.BatchRPC(calls)
+		if err == nil {
+			return errs
+		}
+		if err != ErrBatchNotSupported {
+			errs = make([]error, len(calls))
+			for i := range errs {
+				errs[i] = err
+			}
+			return errs
+		}
+		log.Println(endpoint, "does not accept batch requests, falling back to single calls")
+		rpcClient.mx.Lock()
+		rpcClient.noBatch[endpoint] = time.Now()
+		rpcClient.mx.Unlock()
+	}
+	errs := make([]error, len(calls))
+	for i, data := range calls {
+		errs[i] = rpcClient.actualRpcCall(data)
+		if errs[i] != nil && isTransportError(errs[i]) {
+			//no point in trying the remaining calls
+			for j := i + 1; j < len(calls); j++ {
+				errs[j] = errs[i]
+			}
+			break
+		}
+	}
+	return errs
+}
+
+func (rpcClient *Client) mayBatch(endpoint string) bool {
+	rpcClient.mx.RLock()
+	defer rpcClient.mx.RUnlock()
+	rejected, ok := rpcClient.noBatch[endpoint]
+	return !ok || time.Since(rejected) > noBatchRetry
+}
+
+//The node returned a non-200 http status
+type httpStatusError struct {
+	code   int
+	status string
+}
+
+func (e httpStatusError) Error() string {
+	return e.status
+}
+
+//An error of getting to the node at all, as opposed to an error of decoding its answer
+type transportError struct {
+	error
+}
+
+func isTransportError(err error) bool {
+	_, ok := err.(transportError)
+	return ok
+}
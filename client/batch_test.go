@@ -0,0 +1,155 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+//A node answering every call with the method name as the result. The batches are answered
+//in the reverse order, or as the batch handler has it
+type fakeNode struct {
+	batch   func(w http.ResponseWriter, commands []EthCommand)
+	batches int32
+	singles int32
+}
+
+func (f *fakeNode) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	if strings.HasPrefix(strings.TrimSpace(string(body)), "[") {
+		atomic.AddInt32(&f.batches, 1)
+		var commands []EthCommand
+		json.Unmarshal(body, &commands)
+		if f.batch != nil {
+			f.batch(w, commands)
+			return
+		}
+		var answers []string
+		for i := len(commands) - 1; i >= 0; i-- {
+			answers = append(answers, answer(commands[i]))
+		}
+		fmt.Fprint(w, "["+strings.Join(answers, ",")+"]")
+		return
+	}
+	atomic.AddInt32(&f.singles, 1)
+	var com EthCommand
+	json.Unmarshal(body, &com)
+	fmt.Fprint(w, answer(com))
+}
+
+func answer(com EthCommand) string {
+	return fmt.Sprintf(`{"jsonrpc": "2.0", "id": %d, "result": "%s"}`, com.Id, com.Method)
+}
+
+func newTestCalls(t *testing.T, f *fakeNode) (*Client, string, []*CallData) {
+	server := httptest.NewServer(f)
+	t.Cleanup(server.Close)
+	endpoint := strings.TrimPrefix(server.URL, "http://")
+	rpcClient, _ := NewClient(endpoint, false, false)
+	var calls []*CallData
+	for _, method := range []string{"web3_clientVersion", "net_version", "admin_datadir"} {
+		data := rpcClient.NewCallData(method)
+		data.Context.TargetRPCEndpoint = endpoint
+		calls = append(calls, data)
+	}
+	return rpcClient, endpoint, calls
+}
+
+func checkResults(t *testing.T, calls []*CallData, errs []error) {
+	for i, data := range calls {
+		if errs[i] != nil {
+			t.Errorf("%s: %v", data.Command.Method, errs[i])
+			continue
+		}
+		if got := string(data.Response.Result); got != `"`+data.Command.Method+`"` {
+			t.Errorf("%s: got the result %s", data.Command.Method, got)
+		}
+	}
+}
+
+func TestBatchMatchesIDs(t *testing.T) {
+	f := &fakeNode{}
+	rpcClient, _, calls := newTestCalls(t, f)
+	errs, err := rpcClient.BatchRPC(calls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkResults(t, calls, errs)
+}
+
+func TestBatchMissingResponse(t *testing.T) {
+	f := &fakeNode{batch: func(w http.ResponseWriter, commands []EthCommand) {
+		fmt.Fprint(w, "["+answer(commands[0])+","+answer(commands[2])+"]")
+	}}
+	rpcClient, _, calls := newTestCalls(t, f)
+	errs, err := rpcClient.BatchRPC(calls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errs[0] != nil || errs[2] != nil || errs[1] == nil {
+		t.Errorf("expected an error of the second call only, got %v", errs)
+	}
+}
+
+func TestBatchFallback(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		batch    func(w http.ResponseWriter, commands []EthCommand)
+		fallback bool //to the single calls, for the noBatchRetry
+	}{
+		{"a json-rpc error", func(w http.ResponseWriter, commands []EthCommand) {
+			fmt.Fprint(w, `{"jsonrpc": "2.0", "id": null, "error": {"code": -32600, "message": "invalid request"}}`)
+		}, true},
+		{"an empty array", func(w http.ResponseWriter, commands []EthCommand) {
+			fmt.Fprint(w, "[]")
+		}, true},
+		{"bad request", func(w http.ResponseWriter, commands []EthCommand) {
+			http.Error(w, "batches not allowed", http.StatusBadRequest)
+		}, true},
+		{"method not allowed", func(w http.ResponseWriter, commands []EthCommand) {
+			http.Error(w, "batches not allowed", http.StatusMethodNotAllowed)
+		}, true},
+		{"unauthorized", func(w http.ResponseWriter, commands []EthCommand) {
+			http.Error(w, "who are you", http.StatusUnauthorized)
+		}, false},
+		{"a failing node", func(w http.ResponseWriter, commands []EthCommand) {
+			http.Error(w, "busy", http.StatusServiceUnavailable)
+		}, false},
+	} {
+		f := &fakeNode{batch: tc.batch}
+		rpcClient, endpoint, calls := newTestCalls(t, f)
+		errs := rpcClient.callAll(calls...)
+		if !tc.fallback {
+			for i := range errs {
+				if errs[i] == nil {
+					t.Errorf("%s: no error of %s", tc.name, calls[i].Command.Method)
+				}
+			}
+			if !rpcClient.mayBatch(endpoint) || f.singles != 0 {
+				t.Errorf("%s: fell back to the single calls", tc.name)
+			}
+			continue
+		}
+		checkResults(t, calls, errs)
+		if rpcClient.mayBatch(endpoint) || f.singles != int32(len(calls)) {
+			t.Errorf("%s: did not fall back to the single calls", tc.name)
+		}
+		rpcClient.callAll(calls...)
+		if f.batches != 1 {
+			t.Errorf("%s: the batch was tried again at once", tc.name)
+		}
+		//Until the endpoint is given another chance
+		rpcClient.noBatch[endpoint] = time.Now().Add(-noBatchRetry - time.Second)
+		f.batch = nil
+		checkResults(t, calls, rpcClient.callAll(calls...))
+		if f.batches != 2 || !rpcClient.mayBatch(endpoint) {
+			t.Errorf("%s: the batch was not tried again after the noBatchRetry", tc.name)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package client
+
+import (
+	"errors"
+	"strings"
+)
+
+func init() {
+	RegisterCollector(besuCollector{})
+}
+
+//Besu, formerly Pantheon
+type besuCollector struct{}
+
+func (besuCollector) Name() string {
+	return "Besu"
+}
+
+func (besuCollector) Matches(clientVersion string) bool {
+	return hasPrefixFold(clientVersion, "besu") || strings.HasPrefix(clientVersion, "pantheon")
+}
+
+func (besuCollector) CollectNodeInfo(rpcClient *Client, node *Node, fromScratch bool) error {
+	return rpcClient.collectAdminNodeInfo(besuCollector{}, node, fromScratch)
+}
+
+func (besuCollector) PeersMethod() string {
+	return "admin_peers"
+}
+
+//Geth format, but the ids are 0x prefixed
+func (besuCollector) ParsePeers(data *CallData) (*PeerArray, error) {
+	return parseGethPeers(data)
+}
+
+func (besuCollector) TxpoolStatusMethod() string {
+	return "txpool_besuStatistics"
+}
+
+//txpool_besuStatistics does not tell pending from queued, so everything is counted as pending
+func (besuCollector) ParseTxpoolStatus(data *CallData) (*TxpoolStatusSample, error) {
+	stats, ok := data.ParsedResult.(*BesuTxpoolStatistics)
+	if !ok {
+		return nil, errors.New("could not parse the result of txpool_besuStatistics")
+	}
+	status := &TxpoolStatusSample{Pending: HexString(stats.LocalCount + stats.RemoteCount), Queued: HexString(0)}
+	status.stamp()
+	return status, nil
+}
+
+func (besuCollector) TxpoolMethod() string {
+	return "txpool_besuTransactions"
+}
+
+func (besuCollector) PendingTxsMethod() string {
+	return "txpool_besuTransactions"
+}
+
+//txpool_besuTransactions tells only the hashes, and does not tell pending from queued,
+//so everything is taken as pending
+func (besuCollector) ParsePendingTxs(data *CallData) ([]*Transaction, []string, error) {
+	txs, ok := data.ParsedResult.(*BesuPoolTxs)
+	if !ok {
+		return nil, nil, errors.New("could not parse the result of txpool_besuTransactions")
+	}
+	var pending []*Transaction
+	var hashes []string
+	for _, tx := range *txs {
+		pending = append(pending, &Transaction{Hash: tx.Hash})
+		hashes = append(hashes, tx.Hash)
+	}
+	return pending, hashes, nil
+}
+
+func (besuCollector) Methods() [][]string {
+	return BesuCommsSet
+}
+
+func (besuCollector) Results() map[string]func() interface{} {
+	return map[string]func() interface{}{
+		"txpool_besuStatistics":   func() interface{} { return &BesuTxpoolStatistics{} },
+		"txpool_besuTransactions": func() interface{} { return &BesuPoolTxs{} },
+	}
+}
+
+func (n *Node) IsPantheon() bool {
+	return strings.HasPrefix(n.ClientVersion, "pantheon")
+}
+
+//Besu is the Pantheon renamed
+func (n *Node) IsBesu() bool {
+	return besuCollector{}.Matches(n.ClientVersion)
+}
+
+//txpool_besuStatistics
+type BesuTxpoolStatistics struct {
+	MaxSize     int `json:"maxSize"`
+	LocalCount  int `json:"localCount"`
+	RemoteCount int `json:"remoteCount"`
+}
+
+//txpool_besuTransactions
+type BesuPoolTxs []struct {
+	Hash                      string `json:"hash"`
+	IsReceivedFromLocalSource bool   `json:"isReceivedFromLocalSource"`
+	AddedToPoolAt             string `json:"addedToPoolAt"`
+}
+
+var BesuCommsSet = [][]string{GenericRpcEthComms, GenericRpcWeb3Comms, GenericRpcNetComms, BesuRpcAdminComms, BesuRpcTxpoolComms,
+	BesuRpcCliqueComms, BesuRpcIbftComms, BesuRpcQbftComms, BesuRpcDebugComms, BesuRpcMinerComms, BesuRpcPermComms, BesuRpcPrivComms, BesuRpcOtherComms}
+
+var BesuRpcAdminComms = []string{"admin_addPeer", "admin_changeLogLevel", "admin_generateLogBloomCache", "admin_logsRepairCache",
+	"admin_logsRemoveCache", "admin_nodeInfo", "admin_peers", "admin_removePeer"}
+
+var BesuRpcTxpoolComms = []string{"txpool_besuPendingTransactions", "txpool_besuStatistics", "txpool_besuTransactions"}
+
+var BesuRpcCliqueComms = []string{"clique_discard", "clique_getSigners", "clique_getSignersAtHash", "clique_proposals", "clique_propose",
+	"clique_getSignerMetrics"}
+
+var BesuRpcIbftComms = []string{"ibft_discardValidatorVote", "ibft_getPendingVotes", "ibft_getSignerMetrics", "ibft_getValidatorsByBlockHash",
+	"ibft_getValidatorsByBlockNumber", "ibft_proposeValidatorVote"}
+
+var BesuRpcQbftComms = []string{"qbft_discardValidatorVote", "qbft_getPendingVotes", "qbft_getSignerMetrics", "qbft_getValidatorsByBlockHash",
+	"qbft_getValidatorsByBlockNumber", "qbft_proposeValidatorVote"}
+
+var BesuRpcDebugComms = []string{"debug_accountRange", "debug_batchSendRawTransaction", "debug_getBadBlocks", "debug_getRawBlock",
+	"debug_getRawHeader", "debug_getRawReceipts", "debug_getRawTransaction", "debug_metrics", "debug_replayBlock", "debug_resyncWorldstate",
+	"debug_setHead", "debug_standardTraceBlockToFile", "debug_standardTraceBadBlockToFile", "debug_storageRangeAt", "debug_traceBlock",
+	"debug_traceBlockByHash", "debug_traceBlockByNumber", "debug_traceCall", "debug_traceTransaction"}
+
+var BesuRpcMinerComms = []string{"miner_changeTargetGasLimit", "miner_setCoinbase", "miner_setEtherbase", "miner_start", "miner_stop"}
+
+var BesuRpcPermComms = []string{"perm_addAccountsToAllowlist", "perm_addNodesToAllowlist", "perm_getAccountsAllowlist", "perm_getNodesAllowlist",
+	"perm_reloadPermissionsFromFile", "perm_removeAccountsFromAllowlist", "perm_removeNodesFromAllowlist"}
+
+var BesuRpcPrivComms = []string{"priv_call", "priv_createPrivacyGroup", "priv_debugGetStateRoot", "priv_deletePrivacyGroup",
+	"priv_distributeRawTransaction", "priv_findPrivacyGroup", "priv_getCode", "priv_getEeaTransactionCount", "priv_getFilterChanges",
+	"priv_getFilterLogs", "priv_getLogs", "priv_getPrivacyPrecompileAddress", "priv_getPrivateTransaction", "priv_getTransactionCount",
+	"priv_getTransactionReceipt", "priv_newFilter", "priv_uninstallFilter", "eea_sendRawTransaction"}
+
+var BesuRpcOtherComms = []string{"eth_chainId", "eth_feeHistory", "eth_getMinerDataByBlockHash", "eth_getMinerDataByBlockNumber",
+	"eth_getProof", "eth_maxPriorityFeePerGas", "net_enode", "net_services", "plugins_reloadPluginConfig", "trace_block", "trace_replayBlockTransactions",
+	"trace_transaction"}
@@ -0,0 +1,207 @@
+package client
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+)
+
+//IBFT/QBFT. The usual failure of a BFT network is not a stuck node but too few validators online to reach the quorum,
+//so the validator set is collected with the rest of the node info and matched against the reachable nodes.
+//GoQuorum serves both IBFT and QBFT over the istanbul_ api, Besu has the qbft_ and ibft_ ones
+
+//What a node knows about the BFT consensus
+type BFTInfo struct {
+	API          string         //"istanbul" (GoQuorum), "qbft" or "ibft" (Besu)
+	Validators   []string       //sorted, lower case
+	NodeAddress  string         //the validator address of the node itself
+	Proposals    map[string]int //blocks proposed by the validators in the last Window blocks
+	Window       int
+	PendingVotes PendingVotes //the votes cast by this node
+	Sampled      MyTime
+}
+
+func (bi *BFTInfo) IsValidator() bool {
+	for _, v := range bi.Validators {
+		if v == bi.NodeAddress {
+			return true
+		}
+	}
+	return false
+}
+
+//The node's own address is what GoQuorum calls istanbul_nodeAddress, and Besu reports as eth_coinbase
+func (rpcClient *Client) collectBFTInfo(node *Node) {
+	api := node.consensus
+	var validators, self, activity, votes *CallData
+	if api == consensusIstanbul {
+		validators = rpcClient.newNodeCall(node, "istanbul_getValidators")
+		self = rpcClient.newNodeCall(node, "istanbul_nodeAddress")
+		activity = rpcClient.newNodeCall(node, "istanbul_status")
+		votes = rpcClient.newNodeCall(node, "istanbul_candidates")
+	} else {
+		validators = rpcClient.newNodeCall(node, api+"_getValidatorsByBlockNumber", "latest")
+		self = rpcClient.newNodeCall(node, "eth_coinbase")
+		activity = rpcClient.newNodeCall(node, api+"_getSignerMetrics")
+		votes = rpcClient.newNodeCall(node, api+"_getPendingVotes")
+	}
+	errs := rpcClient.callAll(validators, self, activity, votes)
+	if errs[0] != nil || !validators.Parsed {
+		return
+	}
+	bi := &BFTInfo{API: api, Proposals: map[string]int{}, PendingVotes: PendingVotes{}}
+	bi.Validators = validators.ParsedResult.(*AddressList).normalized()
+	if errs[1] == nil && self.Parsed {
+		bi.NodeAddress = strings.ToLower(string(*self.ParsedResult.(*StringResult)))
+	}
+	if errs[2] == nil && activity.Parsed {
+		switch a := activity.ParsedResult.(type) {
+		case *SealerStatus:
+			bi.Window = a.NumBlocks
+			for v, n := range a.SealerActivity {
+				bi.Proposals[strings.ToLower(v)] = n
+			}
+		case *SignerMetrics:
+			bi.Window = besuMetricsWindow(node)
+			for _, m := range *a {
+				bi.Proposals[strings.ToLower(m.Address)] = int(m.ProposedBlockCount)
+			}
+		}
+	}
+	if errs[3] == nil && votes.Parsed {
+		for v, add := range *votes.ParsedResult.(*PendingVotes) {
+			bi.PendingVotes[strings.ToLower(v)] = add
+		}
+	}
+	bi.Sampled = MyTime(time.Now())
+	node.BFT = bi
+}
+
+//The validator set of the network against the reachable nodes
+type BFTReport struct {
+	API        string
+	Validators []string //as seen by the most advanced reachable node
+	F          int      //faulty validators tolerated
+	Quorum     int      //2F+1
+	Online     []string //validators of the reachable nodes
+	Offline    []string //validators of the unreachable nodes
+	Unknown    []string //validators none of the known nodes claims
+	Proposals  map[string]int
+	Window     int
+	NodeOf     map[string]NodeID //the node of each validator, if known
+}
+
+//Returns nil if none of the nodes runs IBFT/QBFT
+func (bcn *BlockchainNet) BFTReport() *BFTReport {
+	var best, longest *Node //the most advanced node, and the one with the longest view of the proposals
+	nodeOf := map[string]*Node{}
+	for _, n := range bcn.sortedNodes() {
+		if n.BFT == nil {
+			continue
+		}
+		if len(n.BFT.NodeAddress) > 0 {
+			nodeOf[n.BFT.NodeAddress] = n
+		}
+		if !n.IsReachable() {
+			continue
+		}
+		if best == nil || n.blockNumber() > best.blockNumber() {
+			best = n
+		}
+		if longest == nil || n.BFT.Window > longest.BFT.Window {
+			longest = n
+		}
+	}
+	if best == nil {
+		return nil
+	}
+	rep := &BFTReport{API: best.BFT.API, Validators: best.BFT.Validators, Window: longest.BFT.Window, Proposals: longest.BFT.Proposals}
+	//N = 3F+1 at best. The quorum is ceil(2N/3), which is 2F+1 for N = 3F+1
+	count := len(rep.Validators)
+	rep.F = (count - 1) / 3
+	rep.Quorum = (2*count + 2) / 3
+	rep.NodeOf = map[string]NodeID{}
+	for _, v := range rep.Validators {
+		n, ok := nodeOf[v]
+		switch {
+		case !ok:
+			rep.Unknown = append(rep.Unknown, v)
+			continue
+		case n.IsReachable():
+			rep.Online = append(rep.Online, v)
+		default:
+			rep.Offline = append(rep.Offline, v)
+		}
+		rep.NodeOf[v] = n.ID
+	}
+	return rep
+}
+
+//"online", "offline" or "unknown"
+func (br *BFTReport) StatusOf(validator string) string {
+	for _, v := range br.Online {
+		if v == validator {
+			return "online"
+		}
+	}
+	for _, v := range br.Offline {
+		if v == validator {
+			return "offline"
+		}
+	}
+	return "unknown"
+}
+
+//Even if all the validators not found among the nodes are up, they are not enough for the quorum
+func (br *BFTReport) QuorumLost() bool {
+	return len(br.Online)+len(br.Unknown) < br.Quorum
+}
+
+var validatorAddress = regexp.MustCompile("^0x[0-9a-fA-F]{40}$")
+
+//Has the node vote for adding (add == true) or removing the validator
+func (rpcClient *Client) ProposeValidatorVote(id NodeID, address string, add bool) error {
+	return rpcClient.validatorVote(id, address, true, add)
+}
+
+//Withdraws the node's pending vote on the validator
+func (rpcClient *Client) DiscardValidatorVote(id NodeID, address string) error {
+	return rpcClient.validatorVote(id, address, false, false)
+}
+
+func (rpcClient *Client) validatorVote(id NodeID, address string, propose bool, add bool) error {
+	node, ok := rpcClient.NetModel().Nodes[id]
+	if !ok {
+		return errors.New("unknown node " + string(id))
+	}
+	if node.BFT == nil {
+		return errors.New(node.ShortName + " does not run IBFT/QBFT")
+	}
+	if !validatorAddress.MatchString(address) {
+		return errors.New("not a valid address: " + address)
+	}
+	var data *CallData
+	switch {
+	case node.BFT.API == consensusIstanbul && propose:
+		data = rpcClient.newNodeCall(node, "istanbul_propose", address, add)
+	case node.BFT.API == consensusIstanbul:
+		data = rpcClient.newNodeCall(node, "istanbul_discard", address)
+	case propose:
+		data = rpcClient.newNodeCall(node, node.BFT.API+"_proposeValidatorVote", address, add)
+	default:
+		data = rpcClient.newNodeCall(node, node.BFT.API+"_discardValidatorVote", address)
+	}
+	err := rpcClient.actualRpcCall(data)
+	if err != nil {
+		return err
+	}
+	if data.Response.Error != nil {
+		return *data.Response.Error
+	}
+	//Show the vote without waiting for the next rescan
+	cp := node.clone()
+	rpcClient.collectBFTInfo(cp)
+	rpcClient.updateNode(id, func(n *Node) { n.BFT = cp.BFT })
+	return nil
+}
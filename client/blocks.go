@@ -0,0 +1,179 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//The blocks, the transactions and the receipts as the eth_ api returns them. The blocks come with either
+//the transaction hashes or the full transactions, depending on the second parameter of the call
+
+type Block struct {
+	Number           HexString         `json:"number"`
+	Hash             string            `json:"hash"` //empty if the node has no such block
+	ParentHash       string            `json:"parentHash"`
+	Timestamp        HexString         `json:"timestamp"`
+	Miner            string            `json:"miner"`
+	Difficulty       *BigHex           `json:"difficulty"`
+	TotalDifficulty  *BigHex           `json:"totalDifficulty"`
+	GasLimit         HexString         `json:"gasLimit"`
+	GasUsed          HexString         `json:"gasUsed"`
+	BaseFeePerGas    *BigHex           `json:"baseFeePerGas"` //since London
+	Size             HexString         `json:"size"`
+	ExtraData        string            `json:"extraData"`
+	StateRoot        string            `json:"stateRoot"`
+	TransactionsRoot string            `json:"transactionsRoot"`
+	ReceiptsRoot     string            `json:"receiptsRoot"`
+	Nonce            string            `json:"nonce"`
+	Uncles           []string          `json:"uncles"`
+	Transactions     BlockTransactions `json:"transactions"`
+}
+
+func (b *Block) Time() MyTime {
+	return MyTime(time.Unix(int64(b.Timestamp), 0))
+}
+
+//The transactions of a block. Only the Hash is set if the block was asked for without the full transactions
+type BlockTransactions []Transaction
+
+func (bt *BlockTransactions) UnmarshalJSON(raw []byte) error {
+	var items []json.RawMessage
+	if err := json.Unmarshal(raw, &items); err != nil {
+		return err
+	}
+	txs := make(BlockTransactions, len(items))
+	for i, item := range items {
+		if len(item) > 0 && item[0] == '"' {
+			if err := json.Unmarshal(item, &txs[i].Hash); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := json.Unmarshal(item, &txs[i]); err != nil {
+			return err
+		}
+	}
+	*bt = txs
+	return nil
+}
+
+type Transaction struct {
+	Hash                 string     `json:"hash"` //empty if the node has no such transaction
+	BlockHash            string     `json:"blockHash"`
+	BlockNumber          *HexString `json:"blockNumber"` //nil while pending
+	TransactionIndex     *HexString `json:"transactionIndex"`
+	From                 string     `json:"from"`
+	To                   string     `json:"to"` //empty for a contract creation
+	Value                *BigHex    `json:"value"`
+	Gas                  HexString  `json:"gas"`
+	GasPrice             *BigHex    `json:"gasPrice"`
+	MaxFeePerGas         *BigHex    `json:"maxFeePerGas"`
+	MaxPriorityFeePerGas *BigHex    `json:"maxPriorityFeePerGas"`
+	Nonce                HexString  `json:"nonce"`
+	Input                string     `json:"input"`
+	Type                 HexString  `json:"type"`
+}
+
+func (tx *Transaction) IsPending() bool {
+	return tx.BlockNumber == nil
+}
+
+func (tx *Transaction) IsContractCreation() bool {
+	return len(tx.To) == 0
+}
+
+type Receipt struct {
+	TransactionHash   string     `json:"transactionHash"` //empty if the node has no such receipt
+	TransactionIndex  HexString  `json:"transactionIndex"`
+	BlockHash         string     `json:"blockHash"`
+	BlockNumber       HexString  `json:"blockNumber"`
+	From              string     `json:"from"`
+	To                string     `json:"to"`
+	ContractAddress   string     `json:"contractAddress"`
+	GasUsed           HexString  `json:"gasUsed"`
+	CumulativeGasUsed HexString  `json:"cumulativeGasUsed"`
+	EffectiveGasPrice *BigHex    `json:"effectiveGasPrice"`
+	Status            *HexString `json:"status"` //nil before Byzantium
+	Logs              []Log      `json:"logs"`
+}
+
+//Reverted transactions have the status 0
+func (r *Receipt) Succeeded() bool {
+	return r.Status == nil || *r.Status == 1
+}
+
+type Log struct {
+	Address         string    `json:"address"`
+	Topics          []string  `json:"topics"`
+	Data            string    `json:"data"`
+	BlockNumber     HexString `json:"blockNumber"`
+	TransactionHash string    `json:"transactionHash"`
+	LogIndex        HexString `json:"logIndex"`
+	Removed         bool      `json:"removed"`
+}
+
+//A quantity which may not fit an int64, as the wei amounts do
+type BigHex struct {
+	big.Int
+}
+
+func (b *BigHex) UnmarshalJSON(raw []byte) error {
+	if string(raw) == "null" {
+		return nil
+	}
+	text, err := strconv.Unquote(string(raw))
+	if err != nil {
+		text = string(raw)
+	}
+	if strings.HasPrefix(text, "0x") || strings.HasPrefix(text, "0X") {
+		if len(text) == 2 {
+			b.SetInt64(0)
+			return nil
+		}
+		if _, ok := b.SetString(text[2:], 16); !ok {
+			return errors.New("invalid hex quantity: " + text)
+		}
+		return nil
+	}
+	if _, ok := b.SetString(text, 10); !ok {
+		return errors.New("invalid quantity: " + text)
+	}
+	return nil
+}
+
+func (b *BigHex) MarshalJSON() ([]byte, error) {
+	return []byte(`"0x` + b.Text(16) + `"`), nil
+}
+
+func (b *BigHex) String() string {
+	if b == nil {
+		return ""
+	}
+	return b.Int.String()
+}
+
+//The amount in wei as ether
+func (b *BigHex) Ether() string {
+	return b.scaled(18)
+}
+
+//The amount in wei as gwei, the gas prices
+func (b *BigHex) Gwei() string {
+	return b.scaled(9)
+}
+
+func (b *BigHex) scaled(decimals int) string {
+	if b == nil {
+		return ""
+	}
+	unit := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil)
+	s := new(big.Rat).SetFrac(&b.Int, unit).FloatString(decimals)
+	if strings.Contains(s, ".") {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+	return s
+}
@@ -5,34 +5,61 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/san-lab/toolsmith/abi"
+	"github.com/san-lab/toolsmith/store"
 	"github.com/san-lab/toolsmith/templates"
 	"io/ioutil"
 	"log"
 	"net"
 	"net/http"
 	"os"
-	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
 //A rest api client, wrapping an http client
 //The struct also contains a map of addresses of known nodes' end-points
 //The field Port - to memorize the default Port (a bit of a stretch)
+//
+//The client is safe for concurrent use. The network model is copy-on-write: the probes
+//(discovery, rescans, heartbeats) are serialized, work on a private copy of the model and publish it when done,
+//while the readers get an immutable snapshot from NetModel().
 type Client struct {
 	DefaultRPCEndpoint   string // host:port
 	UserAgent            string
 	httpClient           HttpClient
-	seq                  uint
+	seq                  uint64
 	renderer             *templates.Renderer
-	LocalInfo            CallContext
-	NetModel             BlockchainNet
+	localInfo            CallContext
+	netModel             *BlockchainNet
 	DefaultRPCPort       string
-	DebugMode            bool
-	UnreachableAddresses map[string]MyTime
+	debugMode            int32
+	unreachableAddresses map[string]MyTime
 	MockMode             bool
 	dumpRPC              bool
 	blockedAddresses     map[string]bool
+	noBatch              map[string]time.Time //endpoints which have rejected a batch request, and when
+	DefaultWSPort        string               //websocket port of the nodes, no subscriptions if empty
+	SubscribePendingTxs  bool
+	subs                 map[NodeID]*headSubscription
+	cliqueBallots        []*CliqueBallot //the signer votes cast from the frontend
+	forkReport           *ForkReport     //the latest fork check
+	forkModel            *BlockchainNet  //the model the forkReport is of
+	series               seriesStore     //the history of the samples
+	store                *store.Store    //where the samples and the peer changes are kept over restarts, may be nil
+	rpcStats             map[string]*EndpointStats
+	abis                 *abi.Registry                //the contract ABIs to decode the calls and the logs with, may be nil
+	poolSeen             map[NodeID]map[string]MyTime //when the transactions in the pools were first seen
+	fees                 feeTrackers                  //the fees of the latest blocks, per node
+	subsMx               sync.Mutex
+	endpoints            map[string]*EndpointConfig //per-endpoint scheme, credentials and TLS
+	Workers              int                        //the size of the worker pool used by discovery and rescans
+	progress             progressTracker
+	probeMx              sync.Mutex   //serializes the probes modifying the model
+	modelMx              sync.RWMutex //guards the netModel pointer
+	mx                   sync.RWMutex //guards the remaining mutable state
 }
 
 type HttpClient interface {
@@ -40,6 +67,7 @@ type HttpClient interface {
 }
 
 const defaultTimeout = 3 * time.Second
+const defaultRPCPort = "8545" //if the entry point does not tell
 
 //Creates a new rest api client
 //If something like ("www.node:8666",8545) is passed, an error is thrown
@@ -55,33 +83,22 @@ func NewClient(ethHost string, mock bool, dump bool) (c *Client, err error) {
 	}
 
 	c.DefaultRPCEndpoint = ethHost
-	c.DefaultRPCPort = strings.Split(ethHost, ":")[1]
+	c.DefaultRPCPort = defaultRPCPort
+	if _, port, perr := net.SplitHostPort(ethHost); perr == nil && !isIPCAddress(ethHost) {
+		c.DefaultRPCPort = port
+	}
 	c.seq = 0
 	//TODO handle error
-	c.LocalInfo, _ = GetLocalInfo()
-	c.NetModel = *NewBlockchainNet()
-	c.UnreachableAddresses = map[string]MyTime{}
+	c.localInfo, _ = GetLocalInfo()
+	c.netModel = NewBlockchainNet()
+	c.unreachableAddresses = map[string]MyTime{}
 	c.blockedAddresses = map[string]bool{}
+	c.noBatch = map[string]time.Time{}
+	c.subs = map[NodeID]*headSubscription{}
+	c.Workers = DefaultDiscoveryWorkers
 	return
 }
 
-func (rpcClient *Client) tryToFindRPCAddress(nodeid NodeID, rpcHost string, rpcPort int) (rpcAddress string, found bool) {
-	rpcClient.SetTimeout(time.Millisecond * 500)
-	defer rpcClient.SetTimeout(defaultTimeout)
-
-	data := rpcClient.NewCallData("web3_clientVersion")
-
-	for i := rpcPort - 10; i < rpcPort+10; i++ {
-		data.Context.TargetRPCEndpoint = rpcHost + ":" + strconv.Itoa(i)
-		err := rpcClient.actualRpcCall(data)
-		if err == nil {
-			return data.Context.TargetRPCEndpoint, true
-		}
-	}
-
-	return "", false
-}
-
 //The name says it all
 func (rpcClient *Client) SetTimeout(timeout time.Duration) {
 	if !rpcClient.MockMode {
@@ -101,76 +118,143 @@ func (rpcClient *Client) RPC(data *CallData) (err error) {
 	return err
 }
 
+//Returns the current snapshot of the network model. The snapshot must not be modified.
+func (rpcClient *Client) NetModel() *BlockchainNet {
+	rpcClient.modelMx.RLock()
+	defer rpcClient.modelMx.RUnlock()
+	return rpcClient.netModel
+}
+
+//Replaces the network model with a new version
+func (rpcClient *Client) publish(bcn *BlockchainNet) {
+	rpcClient.modelMx.Lock()
+	defer rpcClient.modelMx.Unlock()
+	rpcClient.netModel = bcn
+}
+
+//Replaces a single node of the model with its modified copy. Returns false if the node is not in the model.
+//Waits for the running probe, if any, as the probe would publish its copy of the model over the change.
+//Not to be called with the probeMx held
+func (rpcClient *Client) updateNode(id NodeID, modify func(n *Node)) bool {
+	rpcClient.probeMx.Lock()
+	defer rpcClient.probeMx.Unlock()
+	rpcClient.modelMx.Lock()
+	defer rpcClient.modelMx.Unlock()
+	n, ok := rpcClient.netModel.Nodes[id]
+	if !ok {
+		return false
+	}
+	bcn := *rpcClient.netModel
+	bcn.graph = &graphCache{}
+	bcn.Nodes = make(map[NodeID]*Node, len(rpcClient.netModel.Nodes))
+	for k, v := range rpcClient.netModel.Nodes {
+		bcn.Nodes[k] = v
+	}
+	cp := n.clone()
+	modify(cp)
+	bcn.Nodes[id] = cp
+	rpcClient.netModel = &bcn
+	return true
+}
+
+//A copy of the call context of this machine
+func (rpcClient *Client) LocalInfo() CallContext {
+	rpcClient.mx.RLock()
+	defer rpcClient.mx.RUnlock()
+	return rpcClient.localInfo
+}
+
+func (rpcClient *Client) ToggleRawMode() {
+	rpcClient.mx.Lock()
+	defer rpcClient.mx.Unlock()
+	rpcClient.localInfo.RawMode = !rpcClient.localInfo.RawMode
+}
+
+func (rpcClient *Client) DebugMode() bool {
+	return atomic.LoadInt32(&rpcClient.debugMode) == 1
+}
+
+func (rpcClient *Client) SetDebugMode(on bool) {
+	var i int32
+	if on {
+		i = 1
+	}
+	atomic.StoreInt32(&rpcClient.debugMode, i)
+}
+
+//A copy of the addresses found unreachable since the last discovery
+func (rpcClient *Client) UnreachableAddresses() map[string]MyTime {
+	rpcClient.mx.RLock()
+	defer rpcClient.mx.RUnlock()
+	ua := make(map[string]MyTime, len(rpcClient.unreachableAddresses))
+	for a, t := range rpcClient.unreachableAddresses {
+		ua[a] = t
+	}
+	return ua
+}
+
+func (rpcClient *Client) isBlocked(addr string) bool {
+	rpcClient.mx.RLock()
+	defer rpcClient.mx.RUnlock()
+	return rpcClient.blockedAddresses[addr]
+}
+
 //Just a sequence to number the rest calls (the "id" field)
-//TODO: wrap the sequence as a in a Type
+//Atomic, as the discovery workers call concurrently
 func (rpcClient *Client) nextID() (id uint) {
-	id = rpcClient.seq
-	rpcClient.seq++
-	return
+	return uint(atomic.AddUint64(&rpcClient.seq, 1) - 1)
 }
 
 //Generic call to the ethereum api's. Uses structures corresponding to the api json specs
 //The response gets enclosed in the CallData argument
 func (rpcClient *Client) actualRpcCall(data *CallData) error {
-	if rpcClient.blockedAddresses[data.Context.TargetRPCEndpoint] {
+	if rpcClient.isBlocked(data.Context.TargetRPCEndpoint) {
 		return errors.New("Blocked address:" + data.Context.TargetRPCEndpoint)
 	}
 	data.Command.Id = rpcClient.nextID()
 	jcom, _ := json.Marshal(data.Command)
-	//TODO: allow to define and memorize node-specific ports
-	host := data.Context.TargetRPCEndpoint
-	if !strings.Contains(host, ":") {
-		host = host + ":" + rpcClient.DefaultRPCPort
-	}
-	host = "http://" + host
-
-	req, err := http.NewRequest("POST", host, bytes.NewReader(jcom))
-	if err != nil {
-		rpcClient.log(fmt.Sprintf("%s", err))
-		return err
-	}
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("User-Agent", rpcClient.UserAgent)
-	req.Header.Set("Content-type", "application/json")
-	resp, err := rpcClient.httpClient.Do(req)
-
+	respBytes, err := rpcClient.post(data.Context.TargetRPCEndpoint, jcom)
 	if err != nil {
-		log.Println(err)
-		//rpcClient.NetModel.UnreachableNodes[GhostNode(host)] = MyTime(time.Now())
 		return err
 	}
-	defer resp.Body.Close()
+	data.JsonRequest = string(jcom)
+	return rpcClient.processResponse(data, respBytes)
+}
 
-	if resp.StatusCode != 200 {
-		err = errors.New(resp.Status)
-		return err
-	}
-	respBytes, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		rpcClient.log(fmt.Sprintf("%s", err))
-		return err
-	}
+//Sends the raw json payload to the endpoint over the transport the endpoint calls for and returns the raw response
+func (rpcClient *Client) post(endpoint string, payload []byte) ([]byte, error) {
+	start := time.Now()
+	resp, err := rpcClient.transportFor(endpoint).roundTrip(endpoint, payload)
+	rpcClient.recordRoundTrip(endpoint, time.Since(start), err)
+	return resp, err
+}
 
+//Dumps (if asked to), logs and decodes a single response
+func (rpcClient *Client) processResponse(data *CallData, respBytes []byte) error {
 	if rpcClient.dumpRPC {
-		key, _, _ := net.SplitHostPort(req.URL.Host)
+		key, _, _ := net.SplitHostPort(data.Context.TargetRPCEndpoint)
+		if len(key) == 0 {
+			key = strings.NewReplacer(":", "_", "/", "_").Replace(data.Context.TargetRPCEndpoint)
+		}
 		key = key + "_" + data.Command.Method + ".json"
 		log.Println("dumping " + key)
 		ioutil.WriteFile(key, respBytes, 0644)
 	}
 
-	data.JsonRequest = string(jcom)
 	var buf bytes.Buffer
-	err = json.Indent(&buf, respBytes, "", " ")
+	err := json.Indent(&buf, respBytes, "", " ")
 	if err != nil {
 		rpcClient.log(fmt.Sprint(err))
 	} //irrelevant error not worth returning
 	data.JsonResponse = buf.String()
-	rpcClient.log("Returned:\n" + fmt.Sprintf("%s", resp.Header))
 	rpcClient.log("Returned:\n" + data.JsonResponse)
 	err = Decode(respBytes, data)
 	if err != nil {
 		rpcClient.log(fmt.Sprint(err))
 	}
+	if data.Response.Error != nil {
+		rpcClient.recordRPCError(data.Context.TargetRPCEndpoint)
+	}
 
 	return err
 }
@@ -180,7 +264,7 @@ func (rpcClient *Client) actualRpcCall(data *CallData) error {
 //     - which is complete and only the "ID" integer is meant to be changed
 func (rpcClient *Client) NewCallData(method string) *CallData {
 	com := EthCommand{"2.0", method, []interface{}{}, 0}
-	ctx := rpcClient.LocalInfo // Cloning. This at least is my intention ;-)
+	ctx := rpcClient.LocalInfo() // Cloning. This at least is my intention ;-)
 	calldata := &CallData{Context: ctx, Command: com, Response: EthResponse{}, RandomStuff: map[string]interface{}{}}
 	return calldata
 }
@@ -202,7 +286,7 @@ func GetLocalInfo() (CallContext, error) {
 }
 
 func (rpcClient *Client) log(s string) {
-	if rpcClient.DebugMode {
+	if rpcClient.DebugMode() {
 		log.Println(s)
 	}
 }
@@ -210,20 +294,14 @@ func (rpcClient *Client) log(s string) {
 //validates and formats an RPC method
 //if the string passed corresponds to a valid rpc method (modulo upper/lower case)
 // - brings to the correct form and return true
-func CamelCaseKnownCommand(command *string) bool { //TODO differentiate between Parity and Geth
-	for _, set := range GethCommsSet {
-		for _, cc := range set {
-			if strings.EqualFold(*command, cc) {
-				*command = cc
-				return true
-			}
-		}
-	}
-	for _, set := range ParityCommsSet {
-		for _, cc := range set {
-			if strings.EqualFold(*command, cc) {
-				*command = cc
-				return true
+func CamelCaseKnownCommand(command *string) bool { //TODO differentiate between the client types
+	for _, c := range collectors {
+		for _, set := range c.Methods() {
+			for _, cc := range set {
+				if strings.EqualFold(*command, cc) {
+					*command = cc
+					return true
+				}
 			}
 		}
 	}
@@ -232,8 +310,9 @@ func CamelCaseKnownCommand(command *string) bool { //TODO differentiate between
 
 //Add all possible peers
 func (rpcClient *Client) FullMesh() error {
-	for k1, n1 := range rpcClient.NetModel.Nodes {
-		for k2, n2 := range rpcClient.NetModel.Nodes {
+	bcn := rpcClient.NetModel()
+	for k1, n1 := range bcn.Nodes {
+		for k2, n2 := range bcn.Nodes {
 			if k1 == k2 {
 				continue
 			}
@@ -241,8 +320,14 @@ func (rpcClient *Client) FullMesh() error {
 			if hasalready {
 				continue
 			}
+			//the id is the hash of the key, not the key itself
+			key := enodeKey(n2.Enode)
+			if len(key) == 0 {
+				log.Println("no enode known for", n2.ShortName)
+				continue
+			}
 			for addr := range n2.KnownAddresses {
-				enode := "enode://" + string(n2.ID) + "@" + addr + ":30304"
+				enode := "enode://" + key + "@" + addr + ":30304"
 				callData := rpcClient.NewCallData("admin_addPeer")
 				callData.Context.TargetRPCEndpoint = n1.RPCAddress
 				callData.Command.Params = []interface{}{enode}
@@ -261,34 +346,18 @@ func (rpcClient *Client) FullMesh() error {
 
 //Artificially block calls to certain address
 func (rpcClient *Client) BlockAddress(addr string) {
+	rpcClient.mx.Lock()
+	defer rpcClient.mx.Unlock()
 	rpcClient.blockedAddresses[addr] = true
 }
 
 //Remove artificial block on an address
 func (rpcClient *Client) UnblockAddress(addr string) {
+	rpcClient.mx.Lock()
+	defer rpcClient.mx.Unlock()
 	delete(rpcClient.blockedAddresses, addr)
 }
 
-var GethCommsSet = [][]string{GethRpcMinerComms, GethRpcTxpoolComms, GethRpcAdminComms, GethRpcOtherComms, GenericRpcEthComms, GenericRpcWeb3Comms, GenericRpcNetComms}
-
-var ParityCommsSet = [][]string{GenericRpcWeb3Comms, GenericRpcNetComms, GenericRpcEthComms, RpcPersonalComms, RpcParityComms, RpcParityAccountsComms,
-	RpcParitySetComms, RpcParityPubsubComms, RpcParitySignerComms, RpcParityTraceComms, RpcParityShhComms, RpcParitySecretstoreComms}
-
-var GethRpcOtherComms = []string{"debug_backtraceAt", "personal_ecRecover",
-	"debug_blockProfile", "miner_setGasPrice", "personal_importRawKey", "txpool_inspect",
-	"debug_cpuProfile", "miner_start", "personal_listAccounts", "txpool_status", "debug_dumpBlock", "miner_stop", "personal_lockAccount",
-	"debug_gcStats", "miner_getHashrate", "personal_newAccount", "debug_getBlockRlp", "miner_aetEtherbase", "personal_unlockAccount",
-	"debug_goTrace", "personal_sendTransaction", "debug_memStats", "personal_sign", "debug_seedHashsign",
-	"db_putString", "db_getString", "db_putHex", "db_getHex", "shh_post", "shh_version", "shh_newIdentity", "shh_hasIdentity", "shh_newGroup",
-	"shh_addToGroup", "shh_newFilter", "shh_uninstallFilter", "shh_getFilterChanges", "shh_getMessages"}
-
-var GethRpcMinerComms = []string{"miner_setExtra", "miner_setGasPrice", "miner_start", "miner_stop", "miner_getHashrate", "miner_getEtherbase"}
-
-var GethRpcTxpoolComms = []string{"txpool_content", "txpool_inspect", "txpool_status"}
-
-var GethRpcAdminComms = []string{"admin_addPeer", "admin_datadir", "admin_nodeInfo", "admin_peers", "admin_setSolc",
-	"admin_startRPC", "admin_startWS", "admin_stopRPC", "admin_stopWS"}
-
 var GenericRpcEthComms = []string{"eth_gasPrice", "eth_accounts", "eth_blockNumber", "eth_getBalance", "eth_getStorageAt",
 	"eth_getTransactionCount", "eth_getBlockTransactionCountByHash", "eth_getBlockTransactionCountByNumber", "eth_getUncleCountByBlockHash", "eth_protocolVersion",
 	"eth_syncing", "eth_coinbase", "eth_mining", "eth_hashrate",
@@ -306,32 +375,6 @@ var GenericRpcNetComms = []string{"net_listening", "net_peerCount", "net_version
 var RpcPersonalComms = []string{"personal_listAccounts", "personal_newAccount", "personal_sendTransaction",
 	"personal_signTransaction", "personal_unlockAccount", "personal_sign", "personal_ecRecover"}
 
-var RpcParityComms = []string{"parity_cidV0", "parity_composeTransaction", "parity_consensusCapability", "parity_decryptMessage",
-	"parity_encryptMessage", "parity_futureTransactions", "parity_allTransactions", "parity_getBlockHeaderByNumber", "parity_listOpenedVaults",
-	"parity_listStorageKeys", "parity_listVaults", "parity_localTransactions", "parity_releasesInfo", "parity_signMessage", "parity_versionInfo",
-	"parity_changeVault", "parity_changeVaultPassword", "parity_closeVault", "parity_getVaultMeta", "parity_newVault", "parity_openVault",
-	"parity_setVaultMeta", "parity_accountsInfo", "parity_checkRequest", "parity_defaultAccount", "parity_generateSecretPhrase",
-	"parity_hardwareAccountsInfo", "parity_listAccounts", "parity_phraseToAddress", "parity_postSign", "parity_postTransaction",
-	"parity_defaultExtraData", "parity_extraData", "parity_gasCeilTarget", "parity_gasFloorTarget", "parity_minGasPrice", "parity_transactionsLimit",
-	"parity_devLogs", "parity_devLogsLevels", "parity_chain", "parity_chainId", "parity_chainStatus", "parity_gasPriceHistogram",
-	"parity_netChain", "parity_netPeers", "parity_netPort", "parity_nextNonce", "parity_pendingTransactions", "parity_pendingTransactionsStats",
-	"parity_registryAddress", "parity_removeTransaction", "parity_rpcSettings", "parity_unsignedTransactionsCount",
-	"parity_dappsUrl", "parity_enode", "parity_mode", "parity_nodeKind", "parity_nodeName", "parity_wsUrl"}
-var RpcParityAccountsComms = []string{"parity_allAccountsInfo", "parity_changePassword", "parity_deriveAddressHash", "parity_deriveAddressIndex",
-	"parity_exportAccount", "parity_getDappAddresses", "parity_getDappDefaultAddress", "parity_getNewDappsAddresses", "parity_getNewDappsDefaultAddress",
-	"parity_importGethAccounts", "parity_killAccount", "parity_listGethAccounts", "parity_listRecentDapps", "parity_newAccountFromPhrase",
-	"parity_newAccountFromSecret", "parity_newAccountFromWallet", "parity_removeAddress", "parity_setAccountMeta", "parity_setAccountName",
-	"parity_setDappAddresses", "parity_setDappDefaultAddress", "parity_setNewDappsAddresses", "parity_setNewDappsDefaultAddress",
-	"parity_testPassword"}
-
-var RpcParitySetComms = []string{"parity_acceptNonReservedPeers", "parity_addReservedPeer", "parity_dappsList", "parity_dropNonReservedPeers",
-	"parity_executeUpgrade", "parity_hashContent", "parity_removeReservedPeer", "parity_setAuthor", "parity_setChain", "parity_setEngineSigner",
-	"parity_setExtraData", "parity_setGasCeilTarget", "parity_setGasFloorTarget", "parity_setMaxTransactionGas", "parity_setMinGasPrice",
-	"parity_setMode", "parity_setTransactionsLimit", "parity_upgradeReady"}
-var RpcParityPubsubComms = []string{"parity_subscribe", "parity_unsubscribe"}
-
-var RpcParitySignerComms = []string{"signer_confirmRequest", "signer_confirmRequestRaw", "signer_confirmRequestWithToken", "signer_generateAuthorizationToken",
-						"signer_generateWebProxyAccessToken", "signer_rejectRequest", "signer_requestsToConfirm", "signer_subscribePending", "signer_unsubscribePending"}
-var RpcParityTraceComms = []string{}       //TODO
-var RpcParityShhComms = []string{}         //TODO
-var RpcParitySecretstoreComms = []string{} //TODO
+//The OpenEthereum trace module, as implemented by Nethermind and Erigon
+var RpcTraceComms = []string{"trace_block", "trace_call", "trace_callMany", "trace_filter", "trace_get", "trace_rawTransaction",
+	"trace_replayBlockTransactions", "trace_replayTransaction", "trace_transaction"}
@@ -0,0 +1,190 @@
+package client
+
+import (
+	"strings"
+	"time"
+)
+
+//Clique PoA. A stalled clique chain is usually a signer problem, so the signer set and the recent
+//sealing activity are collected along with the rest of the node info
+
+//clique_getSnapshot. Geth and Nethermind differ in the type of the signers' values, so these are not interpreted
+type CliqueSnapshot struct {
+	Number  HexString              `json:"number"`
+	Hash    string                 `json:"hash"`
+	Signers map[string]interface{} `json:"signers"`
+	Recents map[string]string      `json:"recents"`
+	Votes   []CliqueVote           `json:"votes"`
+	Tally   map[string]CliqueTally `json:"tally"`
+}
+
+type CliqueVote struct {
+	Signer    string    `json:"signer"`
+	Block     HexString `json:"block"`
+	Address   string    `json:"address"`
+	Authorize bool      `json:"authorize"`
+}
+
+type CliqueTally struct {
+	Authorize bool `json:"authorize"`
+	Votes     int  `json:"votes"`
+}
+
+//What a node knows about the clique consensus
+type CliqueInfo struct {
+	Signers       []string       //sorted, lower case
+	Activity      map[string]int //blocks sealed by the signers in the last Window blocks
+	Window        int
+	InturnPercent float64 //of the last Window blocks. Only reported by Geth
+	HasInturn     bool
+	Snapshot      *CliqueSnapshot
+	NodeAddress   string       //the etherbase, which a sealing node signs with
+	Proposals     PendingVotes //clique_proposals, the votes the node casts when sealing
+	Sampled       MyTime
+}
+
+func (ci *CliqueInfo) IsSigner(address string) bool {
+	for _, s := range ci.Signers {
+		if s == strings.ToLower(address) {
+			return true
+		}
+	}
+	return false
+}
+
+//If the node seals, i.e. its etherbase is in the signer set
+func (ci *CliqueInfo) IsSealing() bool {
+	return len(ci.NodeAddress) > 0 && ci.IsSigner(ci.NodeAddress)
+}
+
+func (ci *CliqueInfo) signersKey() string {
+	return strings.Join(ci.Signers, ",")
+}
+
+//Asks the node about clique, in one round trip if possible
+func (rpcClient *Client) collectCliqueInfo(node *Node) {
+	signers := rpcClient.newNodeCall(node, "clique_getSigners")
+	status := rpcClient.newNodeCall(node, "clique_status")
+	metrics := rpcClient.newNodeCall(node, "clique_getSignerMetrics")
+	snapshot := rpcClient.newNodeCall(node, "clique_getSnapshot")
+	self := rpcClient.newNodeCall(node, "eth_coinbase")
+	proposals := rpcClient.newNodeCall(node, "clique_proposals")
+	errs := rpcClient.callAll(signers, status, metrics, snapshot, self, proposals)
+	if errs[0] != nil || !signers.Parsed {
+		return
+	}
+	ci := &CliqueInfo{Activity: map[string]int{}, Proposals: PendingVotes{}}
+	ci.Signers = signers.ParsedResult.(*AddressList).normalized()
+	if errs[1] == nil && status.Parsed {
+		st := status.ParsedResult.(*SealerStatus)
+		ci.Window = st.NumBlocks
+		ci.InturnPercent = st.InturnPercent
+		ci.HasInturn = true
+		for s, n := range st.SealerActivity {
+			ci.Activity[strings.ToLower(s)] = n
+		}
+	} else if errs[2] == nil && metrics.Parsed {
+		ci.Window = besuMetricsWindow(node)
+		for _, m := range *metrics.ParsedResult.(*SignerMetrics) {
+			ci.Activity[strings.ToLower(m.Address)] = int(m.ProposedBlockCount)
+		}
+	}
+	if errs[3] == nil && snapshot.Parsed {
+		ci.Snapshot = snapshot.ParsedResult.(*CliqueSnapshot)
+	}
+	if errs[4] == nil && self.Parsed {
+		ci.NodeAddress = strings.ToLower(string(*self.ParsedResult.(*StringResult)))
+	}
+	if errs[5] == nil && proposals.Parsed {
+		for a, auth := range *proposals.ParsedResult.(*PendingVotes) {
+			ci.Proposals[strings.ToLower(a)] = auth
+		}
+	}
+	ci.Sampled = MyTime(time.Now())
+	node.Clique = ci
+}
+
+//The clique state of the network as seen by all its nodes
+type CliqueReport struct {
+	Signers     []string       //the signer set agreed on by most of the nodes
+	Activity    map[string]int //from the node with the longest view
+	Window      int
+	Inactive    []string //signers that have not sealed a block in the Window
+	Disagreeing []NodeID //nodes seeing a signer set other than Signers
+	Nodes       int      //nodes reporting the clique status
+}
+
+//Returns nil if none of the nodes runs clique.
+//The nodes lagging more than a block behind the highest head are not compared, as their signer set
+//may legitimately be older
+func (bcn *BlockchainNet) CliqueReport() *CliqueReport {
+	var head HexString
+	var nodes []*Node
+	for _, n := range bcn.sortedNodes() {
+		if n.Clique == nil || !n.IsReachable() {
+			continue
+		}
+		nodes = append(nodes, n)
+		if n.blockNumber() > head {
+			head = n.blockNumber()
+		}
+	}
+	if len(nodes) == 0 {
+		return nil
+	}
+	rep := &CliqueReport{Nodes: len(nodes)}
+	votes := map[string]int{}
+	var compared []*Node
+	for _, n := range nodes {
+		if n.blockNumber()+1 < head {
+			continue
+		}
+		compared = append(compared, n)
+		votes[n.Clique.signersKey()]++
+	}
+	var majority string
+	for key, v := range votes {
+		if v > votes[majority] || (v == votes[majority] && key < majority) {
+			majority = key
+		}
+	}
+	for _, n := range compared {
+		if n.Clique.signersKey() == majority {
+			if rep.Signers == nil {
+				rep.Signers = n.Clique.Signers
+			}
+			if n.Clique.Window > rep.Window {
+				rep.Window = n.Clique.Window
+				rep.Activity = n.Clique.Activity
+			}
+		} else {
+			rep.Disagreeing = append(rep.Disagreeing, n.ID)
+		}
+	}
+	//A live signer seals its in-turn blocks, so it has to show up in a window of a couple of rounds
+	if rep.Activity != nil && rep.Window >= 2*len(rep.Signers) {
+		for _, s := range rep.Signers {
+			if rep.Activity[s] == 0 {
+				rep.Inactive = append(rep.Inactive, s)
+			}
+		}
+	}
+	return rep
+}
+
+func (cr *CliqueReport) IsOK() bool {
+	return len(cr.Inactive) == 0 && len(cr.Disagreeing) == 0
+}
+
+func (cr *CliqueReport) IsInactive(signer string) bool {
+	for _, s := range cr.Inactive {
+		if s == signer {
+			return true
+		}
+	}
+	return false
+}
+
+func (ci *CliqueInfo) OutturnPercent() float64 {
+	return 100 - ci.InturnPercent
+}
@@ -0,0 +1,212 @@
+package client
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+//Clique signer voting. A signer is added or removed once more than half of the signers vote for it, a signer node
+//voting with every block it seals for all of its clique_proposals. The votes are cast with the same RPC calls
+//the /node/method pages make, and the ballots cast from here are followed until the signer set changes
+
+//A change of the signer set asked for from toolsmith
+type CliqueBallot struct {
+	Address     string
+	Authorize   bool
+	Nodes       []NodeID //the nodes voting for it
+	Cast        MyTime
+	Landed      bool
+	LandedAt    MyTime    //when the rescan first saw the change, with the head at the LandedBlock
+	LandedBlock HexString //the change is in the signer set by this block
+}
+
+//The ballot and where it stands
+type CliqueBallotStatus struct {
+	CliqueBallot
+	Votes int //votes for the change in the snapshot tally
+}
+
+//The voting on the signer set as seen by the network
+type CliqueVoting struct {
+	Signers []string
+	Needed  int //votes needed to pass: more than half of the signers
+	Block   HexString
+	Tally   map[string]CliqueTally //of the most advanced snapshot
+	Ballots []CliqueBallotStatus
+}
+
+//Has the nodes vote for adding (authorize == true) or removing the signer
+func (rpcClient *Client) ProposeCliqueVote(ids []NodeID, address string, authorize bool) error {
+	voted, err := rpcClient.cliqueVote(ids, "clique_propose", address, authorize)
+	if len(voted) == 0 {
+		return err
+	}
+	address = strings.ToLower(address)
+	rpcClient.mx.Lock()
+	defer rpcClient.mx.Unlock()
+	var ballot *CliqueBallot
+	for _, b := range rpcClient.cliqueBallots {
+		if b.Address == address && b.Authorize == authorize && !b.Landed {
+			ballot = b
+		}
+	}
+	if ballot == nil {
+		ballot = &CliqueBallot{Address: address, Authorize: authorize, Cast: MyTime(time.Now())}
+		rpcClient.cliqueBallots = append(rpcClient.cliqueBallots, ballot)
+	}
+	for _, id := range voted {
+		if !containsNodeID(ballot.Nodes, id) {
+			ballot.Nodes = append(ballot.Nodes, id)
+		}
+	}
+	return err
+}
+
+//Withdraws the nodes' votes on the signer. A ballot no node votes for any more is forgotten
+func (rpcClient *Client) DiscardCliqueVote(ids []NodeID, address string) error {
+	discarded, err := rpcClient.cliqueVote(ids, "clique_discard", address)
+	address = strings.ToLower(address)
+	rpcClient.mx.Lock()
+	defer rpcClient.mx.Unlock()
+	var kept []*CliqueBallot
+	for _, b := range rpcClient.cliqueBallots {
+		if b.Address == address {
+			var nodes []NodeID
+			for _, id := range b.Nodes {
+				if !containsNodeID(discarded, id) {
+					nodes = append(nodes, id)
+				}
+			}
+			b.Nodes = nodes
+			if len(nodes) == 0 {
+				continue
+			}
+		}
+		kept = append(kept, b)
+	}
+	rpcClient.cliqueBallots = kept
+	return err
+}
+
+//Calls the method on every node, returns the nodes on which it succeeded and the failures, if any
+func (rpcClient *Client) cliqueVote(ids []NodeID, method string, params ...interface{}) (done []NodeID, err error) {
+	address := params[0].(string)
+	if !validatorAddress.MatchString(address) {
+		return nil, errors.New("not a valid address: " + address)
+	}
+	if len(ids) == 0 {
+		return nil, errors.New("no node selected")
+	}
+	var failed []string
+	bcn := rpcClient.NetModel()
+	for _, id := range ids {
+		node, ok := bcn.Nodes[id]
+		if !ok {
+			failed = append(failed, "unknown node "+string(id))
+			continue
+		}
+		data := rpcClient.NewCallData(method)
+		data.Context.TargetRPCEndpoint = node.RPCAddress
+		data.Command.Params = params
+		cerr := rpcClient.RPC(data)
+		if cerr == nil && data.Response.Error != nil {
+			cerr = *data.Response.Error
+		}
+		if cerr != nil {
+			failed = append(failed, node.ShortName+": "+cerr.Error())
+			continue
+		}
+		done = append(done, id)
+		//Show the vote without waiting for the next rescan
+		cp := node.clone()
+		rpcClient.collectCliqueInfo(cp)
+		rpcClient.updateNode(id, func(n *Node) { n.Clique = cp.Clique })
+	}
+	if len(failed) > 0 {
+		err = errors.New(strings.Join(failed, "; "))
+	}
+	return
+}
+
+//Returns nil if none of the nodes runs clique
+func (rpcClient *Client) CliqueVoting() *CliqueVoting {
+	bcn := rpcClient.NetModel()
+	rep := bcn.CliqueReport()
+	if rep == nil {
+		return nil
+	}
+	cv := &CliqueVoting{Signers: rep.Signers, Needed: len(rep.Signers)/2 + 1, Tally: map[string]CliqueTally{}}
+	var snapshot *CliqueSnapshot
+	cv.Block, _, snapshot = bcn.cliqueHead(rep.Signers)
+	if snapshot != nil {
+		for a, t := range snapshot.Tally {
+			cv.Tally[strings.ToLower(a)] = t
+		}
+	}
+	rpcClient.mx.RLock()
+	defer rpcClient.mx.RUnlock()
+	for _, b := range rpcClient.cliqueBallots {
+		st := CliqueBallotStatus{CliqueBallot: *b}
+		if t, ok := cv.Tally[b.Address]; ok && t.Authorize == b.Authorize {
+			st.Votes = t.Votes
+		}
+		cv.Ballots = append(cv.Ballots, st)
+	}
+	return cv
+}
+
+//Marks the ballots whose change has made it to the signer set of the rescanned model, so that a ballot lands
+//with the block the change is first seen by, whether the voting is looked at or not
+func (rpcClient *Client) trackCliqueBallots(bcn *BlockchainNet) {
+	rep := bcn.CliqueReport()
+	if rep == nil {
+		return
+	}
+	block, sampled, _ := bcn.cliqueHead(rep.Signers)
+	rpcClient.mx.Lock()
+	defer rpcClient.mx.Unlock()
+	for _, b := range rpcClient.cliqueBallots {
+		if !b.Landed && b.Authorize == containsString(rep.Signers, b.Address) {
+			b.Landed = true
+			b.LandedAt = sampled
+			b.LandedBlock = block
+		}
+	}
+}
+
+//The highest block of the reachable nodes on the signer set, when it was sampled, and the latest snapshot of these nodes
+func (bcn *BlockchainNet) cliqueHead(signers []string) (block HexString, sampled MyTime, snapshot *CliqueSnapshot) {
+	key := strings.Join(signers, ",")
+	for _, n := range bcn.sortedNodes() {
+		if n.Clique == nil || !n.IsReachable() || n.Clique.signersKey() != key {
+			continue
+		}
+		if n.blockNumber() > block {
+			block = n.blockNumber()
+			sampled = n.LastBlockNumberSample.Sampled
+		}
+		if s := n.Clique.Snapshot; s != nil && (snapshot == nil || s.Number > snapshot.Number) {
+			snapshot = s
+		}
+	}
+	return
+}
+
+func containsNodeID(ids []NodeID, id NodeID) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
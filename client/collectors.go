@@ -0,0 +1,187 @@
+package client
+
+import (
+	"errors"
+	"log"
+	"strings"
+)
+
+//A NodeCollector knows how to talk to one Ethereum client implementation.
+//The collectors register themselves from the init() of their files and are matched
+//against the web3_clientVersion of the nodes, so that supporting a new client means adding
+//one file with its collector
+type NodeCollector interface {
+	//The client type, as in "Geth"
+	Name() string
+	//True if the collector handles the client reporting this web3_clientVersion
+	Matches(clientVersion string) bool
+	//Fills in the node info (when fromScratch), the txpool status, the block number and the peers
+	CollectNodeInfo(rpcClient *Client, node *Node, fromScratch bool) error
+	//The call listing the peers of the node
+	PeersMethod() string
+	//The peers from the result of the PeersMethod call, fitted into the Geth structures
+	ParsePeers(data *CallData) (*PeerArray, error)
+	//The call summarizing the txpool of the node
+	TxpoolStatusMethod() string
+	//The txpool status from the result of the TxpoolStatusMethod call
+	ParseTxpoolStatus(data *CallData) (*TxpoolStatusSample, error)
+	//The method listing the transactions in the pool, for the views to link to
+	TxpoolMethod() string
+	//The call listing the pending transactions of the node, for the pools to be compared
+	PendingTxsMethod() string
+	//The pending transactions and the hashes of all the transactions in the pool, the queued as well,
+	//from the result of the PendingTxsMethod call
+	ParsePendingTxs(data *CallData) ([]*Transaction, []string, error)
+	//The RPC methods known to the client
+	Methods() [][]string
+	//Constructors of the results of the client-specific methods, for the Decode to fill in
+	Results() map[string]func() interface{}
+}
+
+var collectors []NodeCollector
+
+//Meant to be called from init(). The collectors are tried in the order of registration
+func RegisterCollector(c NodeCollector) {
+	collectors = append(collectors, c)
+}
+
+func Collectors() []NodeCollector {
+	return append([]NodeCollector{}, collectors...)
+}
+
+func collectorFor(clientVersion string) (NodeCollector, bool) {
+	for _, c := range collectors {
+		if c.Matches(clientVersion) {
+			return c, true
+		}
+	}
+	return nil, false
+}
+
+func (n *Node) collector() (NodeCollector, error) {
+	c, ok := collectorFor(n.ClientVersion)
+	if !ok {
+		return nil, errors.New("unsupported Eth client: " + n.ClientVersion)
+	}
+	return c, nil
+}
+
+//The result of a client-specific method, nil if none of the collectors knows the method
+func newClientResult(method string) interface{} {
+	for _, c := range collectors {
+		if f, ok := c.Results()[method]; ok {
+			return f()
+		}
+	}
+	return nil
+}
+
+//The shared collector of Besu (formerly Pantheon), Nethermind and Erigon. These implement the geth admin api,
+//and are asked for the sync status as well. The txpool is summarized the way of the client's collector
+func (rpcClient *Client) collectAdminNodeInfo(c NodeCollector, node *Node, fromScratch bool) error {
+	nodeInfo := rpcClient.newNodeCall(node, "admin_nodeInfo")
+	txpool := rpcClient.newNodeCall(node, c.TxpoolStatusMethod())
+	syncing := rpcClient.newNodeCall(node, "eth_syncing")
+	blockNo := rpcClient.newNodeCall(node, "eth_blockNumber")
+	peers := rpcClient.newNodeCall(node, "admin_peers")
+	calls := []*CallData{txpool, syncing, blockNo, peers}
+	if fromScratch {
+		calls = append([]*CallData{nodeInfo}, calls...)
+	}
+	errs := rpcClient.callAll(calls...)
+	if fromScratch {
+		if errs[0] != nil {
+			return errs[0]
+		}
+		errs = errs[1:]
+		ni, ok := nodeInfo.ParsedResult.(*NodeInfo)
+		if !ok {
+			log.Printf("expected %T got %T", ni, nodeInfo.ParsedResult)
+			return errors.New("not ok parsing the node info of " + node.ClientType())
+		}
+		FillNodeFromNodeInfo_Admin(node, ni)
+		node.isFromPeer = false
+	}
+	//The txpool api is not enabled on the Besu nodes by default, so the rest is applied without it
+	node.applyTxpoolStatus(c, txpool, errs[0])
+
+	if errs[1] == nil && syncing.Parsed {
+		node.SyncStatus = syncing.ParsedResult.(*SyncStatus)
+	}
+
+	err := node.applyBlockNumber(blockNo, errs[2])
+
+	err = node.applyPeers(peers, errs[3])
+	return err
+}
+
+//Sets the txpool status, or the error reading it, in which case the node has no txpool status
+func (n *Node) applyTxpoolStatus(c NodeCollector, txpool *CallData, callErr error) {
+	n.TxpoolStatus, n.TxpoolError = nil, ""
+	switch {
+	case callErr != nil:
+		n.TxpoolError = callErr.Error()
+	case txpool.Response.Error != nil:
+		n.TxpoolError = txpool.Response.Error.Error()
+	default:
+		status, err := c.ParseTxpoolStatus(txpool)
+		if err != nil {
+			n.TxpoolError = err.Error()
+		}
+		n.TxpoolStatus = status
+	}
+}
+
+//The admin_peers of the Geth format
+func parseGethPeers(data *CallData) (*PeerArray, error) {
+	peers, ok := data.ParsedResult.(*PeerArray)
+	if !ok {
+		return nil, errors.New("could not parse the result of " + data.Command.Method)
+	}
+	return peers, nil
+}
+
+//The txpool_status of the Geth format
+func parseGethTxpoolStatus(data *CallData) (*TxpoolStatusSample, error) {
+	status, ok := data.ParsedResult.(*TxpoolStatusSample)
+	if !ok {
+		return nil, errors.New("could not parse the result of " + data.Command.Method)
+	}
+	return status, nil
+}
+
+//The txpool_content of the Geth format
+func parseGethPendingTxs(data *CallData) ([]*Transaction, []string, error) {
+	content, ok := data.ParsedResult.(*TxpoolContent)
+	if !ok {
+		return nil, nil, errors.New("could not parse the result of " + data.Command.Method)
+	}
+	var pending []*Transaction
+	var hashes []string
+	for _, byNonce := range content.Pending {
+		for _, tx := range byNonce {
+			pending = append(pending, tx)
+			hashes = append(hashes, tx.Hash)
+		}
+	}
+	for _, byNonce := range content.Queued {
+		for _, tx := range byNonce {
+			hashes = append(hashes, tx.Hash)
+		}
+	}
+	return pending, hashes, nil
+}
+
+//Besu, Erigon and Nethermind
+func FillNodeFromNodeInfo_Admin(n *Node, ni *NodeInfo) {
+	n.ID = nodeID(ni.ID, ni.Enode)
+	n.ThisNodeInfo = *ni
+	n.FullName = ni.Name
+	n.Enode = ni.Enode
+	n.ShortName = n.getIdentityShortName(ni.IP)
+	n.setReachable(true)
+}
+
+func hasPrefixFold(s string, prefix string) bool {
+	return len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix)
+}
@@ -0,0 +1,101 @@
+package client
+
+import (
+	"sort"
+	"strings"
+)
+
+//The consensus apis. A node is probed once for the api it answers to; the ones answering
+//to none of them (PoW, Raft, or the api not enabled) are not asked again until the next discovery
+
+const (
+	consensusNone     = "none"
+	consensusClique   = "clique"
+	consensusIstanbul = "istanbul" //GoQuorum, IBFT and QBFT alike
+	consensusQBFT     = "qbft"     //Besu
+	consensusIBFT     = "ibft"     //Besu IBFT 2.0
+)
+
+//clique_getSigners, istanbul_getValidators, qbft_ and ibft_getValidatorsByBlockNumber
+type AddressList []string
+
+//Lower case and sorted
+func (al AddressList) normalized() []string {
+	out := make([]string, len(al))
+	for i, a := range al {
+		out[i] = strings.ToLower(a)
+	}
+	sort.Strings(out)
+	return out
+}
+
+//clique_status (Geth) and istanbul_status (GoQuorum). The activity is counted over the last NumBlocks blocks
+type SealerStatus struct {
+	InturnPercent  float64        `json:"inturnPercent"`
+	NumBlocks      int            `json:"numBlocks"`
+	SealerActivity map[string]int `json:"sealerActivity"`
+}
+
+//clique_, ibft_ and qbft_getSignerMetrics (Besu). By default over the last 100 blocks
+type SignerMetric struct {
+	Address                 string    `json:"address"`
+	ProposedBlockCount      HexString `json:"proposedBlockCount"`
+	LastProposedBlockNumber HexString `json:"lastProposedBlockNumber"`
+}
+
+type SignerMetrics []SignerMetric
+
+const besuSignerMetricsRange = 100
+
+//The window of the Besu signer metrics, shorter on a young chain
+func besuMetricsWindow(node *Node) int {
+	if node.LastBlockNumberSample != nil && int(node.LastBlockNumberSample.BlockNumber) < besuSignerMetricsRange {
+		return int(node.LastBlockNumberSample.BlockNumber)
+	}
+	return besuSignerMetricsRange
+}
+
+//istanbul_candidates, qbft_ and ibft_getPendingVotes, clique_proposals: the votes cast by the node, true to add a validator, false to remove
+type PendingVotes map[string]bool
+
+//The consensus api the node answers to: "clique", "istanbul", "qbft", "ibft" or "none". Empty if not probed yet
+func (n *Node) Consensus() string {
+	return n.consensus
+}
+
+func (rpcClient *Client) collectConsensusInfo(node *Node) {
+	if len(node.consensus) == 0 {
+		node.consensus = rpcClient.detectConsensus(node)
+	}
+	switch node.consensus {
+	case consensusClique:
+		rpcClient.collectCliqueInfo(node)
+	case consensusIstanbul, consensusQBFT, consensusIBFT:
+		rpcClient.collectBFTInfo(node)
+	}
+}
+
+//One round trip asking for the signers/validators in all the dialects. Returns "" if the node could not be asked
+func (rpcClient *Client) detectConsensus(node *Node) string {
+	probes := map[string]*CallData{
+		consensusClique:   rpcClient.newNodeCall(node, "clique_getSigners"),
+		consensusIstanbul: rpcClient.newNodeCall(node, "istanbul_getValidators"),
+		consensusQBFT:     rpcClient.newNodeCall(node, "qbft_getValidatorsByBlockNumber", "latest"),
+		consensusIBFT:     rpcClient.newNodeCall(node, "ibft_getValidatorsByBlockNumber", "latest"),
+	}
+	order := []string{consensusClique, consensusIstanbul, consensusQBFT, consensusIBFT}
+	calls := make([]*CallData, len(order))
+	for i, c := range order {
+		calls[i] = probes[c]
+	}
+	errs := rpcClient.callAll(calls...)
+	for i, c := range order {
+		if errs[i] != nil && isTransportError(errs[i]) {
+			return ""
+		}
+		if errs[i] == nil && calls[i].Parsed {
+			return c
+		}
+	}
+	return consensusNone
+}
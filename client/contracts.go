@@ -0,0 +1,68 @@
+package client
+
+import (
+	"encoding/hex"
+	"errors"
+	"github.com/san-lab/toolsmith/abi"
+	"strings"
+)
+
+//The calls to the contracts, with their ABIs from the registry
+
+func (rpcClient *Client) SetABIRegistry(r *abi.Registry) {
+	rpcClient.mx.Lock()
+	rpcClient.abis = r
+	rpcClient.mx.Unlock()
+}
+
+//The registry of the contract ABIs, may be nil. Its decoding methods are safe to call on nil
+func (rpcClient *Client) ABIs() *abi.Registry {
+	rpcClient.mx.RLock()
+	defer rpcClient.mx.RUnlock()
+	return rpcClient.abis
+}
+
+//The transaction object of eth_call and eth_sendTransaction. The value is in wei, decimal or hex
+func callObject(from, to string, data []byte, value string) (map[string]string, error) {
+	tx := map[string]string{"to": to, "data": "0x" + hex.EncodeToString(data)}
+	if len(from) > 0 {
+		tx["from"] = from
+	}
+	if value = strings.TrimSpace(value); len(value) > 0 && value != "0" {
+		v := &BigHex{}
+		if err := v.UnmarshalJSON([]byte(value)); err != nil || v.Sign() < 0 {
+			return nil, errors.New("not a valid value: " + value)
+		}
+		tx["value"] = "0x" + v.Text(16)
+	}
+	return tx, nil
+}
+
+//Calls the contract at the latest block and returns what the call returned
+func (rpcClient *Client) EthCall(node *Node, from, to string, data []byte, value string) (string, error) {
+	tx, err := callObject(from, to, data, value)
+	if err != nil {
+		return "", err
+	}
+	res, err := rpcClient.explorerCall(node, "eth_call", tx, "latest")
+	if err != nil {
+		return "", err
+	}
+	return string(*res.(*StringResult)), nil
+}
+
+//Sends the transaction from an account the node holds the key of, and returns its hash
+func (rpcClient *Client) SendTransaction(node *Node, from, to string, data []byte, value string) (string, error) {
+	if !validatorAddress.MatchString(from) {
+		return "", errors.New("not a valid sender address: " + from)
+	}
+	tx, err := callObject(from, to, data, value)
+	if err != nil {
+		return "", err
+	}
+	res, err := rpcClient.explorerCall(node, "eth_sendTransaction", tx)
+	if err != nil {
+		return "", err
+	}
+	return string(*res.(*StringResult)), nil
+}
@@ -0,0 +1,165 @@
+package client
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+//Discovery and rescans fan the per-node RPC calls out over a bounded pool of workers.
+//Only the coordinating goroutine touches the NetModel maps; a worker owns the single node it is probing.
+
+const DefaultDiscoveryWorkers = 8
+
+//A snapshot of the progress of the current (or the last) discovery or rescan
+type DiscoveryProgress struct {
+	Running  bool
+	Started  MyTime
+	Finished MyTime
+	Found    int //nodes known so far
+	Probed   int //nodes for which the probe has returned
+	Failed   int //nodes for which the probe has returned an error
+}
+
+func (dp DiscoveryProgress) String() string {
+	return fmt.Sprintf("found: %v, probed: %v, failed: %v", dp.Found, dp.Probed, dp.Failed)
+}
+
+type progressTracker struct {
+	mx       sync.Mutex
+	progress DiscoveryProgress
+}
+
+func (pt *progressTracker) start(found int) {
+	pt.mx.Lock()
+	defer pt.mx.Unlock()
+	pt.progress = DiscoveryProgress{Running: true, Started: MyTime(time.Now()), Found: found}
+}
+
+func (pt *progressTracker) found(n int) {
+	pt.mx.Lock()
+	defer pt.mx.Unlock()
+	pt.progress.Found += n
+}
+
+func (pt *progressTracker) probed(failed bool) {
+	pt.mx.Lock()
+	defer pt.mx.Unlock()
+	pt.progress.Probed++
+	if failed {
+		pt.progress.Failed++
+	}
+}
+
+func (pt *progressTracker) finish() {
+	pt.mx.Lock()
+	defer pt.mx.Unlock()
+	pt.progress.Running = false
+	pt.progress.Finished = MyTime(time.Now())
+	log.Println("Probing finished,", pt.progress)
+}
+
+func (pt *progressTracker) get() DiscoveryProgress {
+	pt.mx.Lock()
+	defer pt.mx.Unlock()
+	return pt.progress
+}
+
+//Returns the progress of the running (or the last finished) discovery/rescan
+func (rpcClient *Client) DiscoveryProgress() DiscoveryProgress {
+	return rpcClient.progress.get()
+}
+
+func (rpcClient *Client) workerCount(jobs int) int {
+	w := rpcClient.Workers
+	if w < 1 {
+		w = 1
+	}
+	if w > jobs {
+		w = jobs
+	}
+	return w
+}
+
+//Runs collectNodeInfo for all the nodes over the worker pool and waits for all of them to return.
+//The nodes have to be distinct - each one is handed to exactly one worker.
+func (rpcClient *Client) collectAll(nodes []*Node, refetch bool) {
+	if len(nodes) == 0 {
+		return
+	}
+	jobs := make(chan *Node)
+	wg := sync.WaitGroup{}
+	for i := 0; i < rpcClient.workerCount(len(nodes)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for node := range jobs {
+				err := rpcClient.collectNodeInfo(node, refetch)
+				if err != nil {
+					log.Println(err)
+				}
+				rpcClient.progress.probed(err != nil)
+			}
+		}()
+	}
+	for _, node := range nodes {
+		jobs <- node
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+//Walks the peer graph breadth-first, one wave of newly found nodes at a time.
+//Within a wave the parents and their peers are visited in the NodeID order, so that
+//the model does not depend on the number of workers nor on the order in which the probes return.
+func (rpcClient *Client) collectNodeInfoRecursively(bcn *BlockchainNet, parent *Node) error {
+	wave := []*Node{parent}
+	for len(wave) > 0 {
+		var next []*Node
+		for _, node := range wave {
+			for _, id := range sortedPeerIDs(node) {
+				if bcn.Nodes[id] != nil {
+					continue
+				}
+				stub := NewNode()
+				stub.ID = id
+				addr := node.Peers[id].PrefAddress()
+				if len(addr) > 0 {
+					stub.KnownAddresses[addr] = true
+					stub.RPCAddress = addr //default RPC port is assumed
+				}
+				bcn.Nodes[id] = stub
+				next = append(next, stub)
+			}
+		}
+		if len(next) == 0 {
+			break
+		}
+		rpcClient.progress.found(len(next))
+		rpcClient.collectAll(next, true)
+		log.Println("Discovery wave done,", rpcClient.progress.get())
+		wave = next
+	}
+	return nil
+}
+
+func sortedPeerIDs(n *Node) []NodeID {
+	ids := make([]NodeID, 0, len(n.Peers))
+	for id := range n.Peers {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+//The model nodes in the NodeID order
+func (bcn *BlockchainNet) sortedNodes() []*Node {
+	nodes := make([]*Node, 0, len(bcn.Nodes))
+	for _, n := range bcn.Nodes {
+		nodes = append(nodes, n)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].ID < nodes[j].ID })
+	return nodes
+}
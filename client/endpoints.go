@@ -0,0 +1,172 @@
+package client
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+//Per-endpoint transport configuration: scheme, credentials, extra headers and TLS.
+//The configurations are keyed by the RPC address; a key may also be just the host (matching any port),
+//or "*" for the endpoints not matched otherwise. Example of the config file:
+//	{
+//	  "10.0.0.5:8545": {"scheme": "https", "username": "rpc", "password": "secret", "caFile": "private-ca.pem"},
+//	  "10.0.0.6":      {"bearerToken": "eyJhbGciOi...", "headers": {"X-Network": "consortium"}},
+//	  "*":             {"jwtSecretFile": "/data/jwtsecret"}
+//	}
+
+const DefaultEndpointsFile = "rpc.endpoints.json"
+
+type EndpointConfig struct {
+	Scheme             string            `json:"scheme,omitempty"` //http (default) or https
+	Path               string            `json:"path,omitempty"`   //appended to the address, e.g. "/rpc" behind a proxy
+	Username           string            `json:"username,omitempty"`
+	Password           string            `json:"password,omitempty"`
+	BearerToken        string            `json:"bearerToken,omitempty"`
+	JWTSecretFile      string            `json:"jwtSecretFile,omitempty"` //hex encoded HS256 secret, as geth's --authrpc.jwtsecret
+	Headers            map[string]string `json:"headers,omitempty"`
+	CAFile             string            `json:"caFile,omitempty"` //PEM bundle of the CAs to trust on top of the system ones
+	CertFile           string            `json:"certFile,omitempty"`
+	KeyFile            string            `json:"keyFile,omitempty"`
+	InsecureSkipVerify bool              `json:"insecureSkipVerify,omitempty"`
+	jwtSecret          []byte
+	tlsConfig          *tls.Config
+	httpClient         *http.Client
+}
+
+//Reads the endpoint configurations from a json file
+func LoadEndpointConfigs(filename string) (map[string]*EndpointConfig, error) {
+	buff, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	configs := map[string]*EndpointConfig{}
+	err = json.Unmarshal(buff, &configs)
+	if err != nil {
+		return nil, err
+	}
+	for key, ec := range configs {
+		if err = ec.prepare(); err != nil {
+			return nil, fmt.Errorf("endpoint %s: %v", key, err)
+		}
+	}
+	return configs, nil
+}
+
+//Loads the secrets and the certificates and builds the http client for the endpoint
+func (ec *EndpointConfig) prepare() error {
+	if len(ec.JWTSecretFile) > 0 {
+		raw, err := ioutil.ReadFile(ec.JWTSecretFile)
+		if err != nil {
+			return err
+		}
+		ec.jwtSecret, err = hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(string(raw)), "0x"))
+		if err != nil {
+			return errors.New("jwt secret is not hex: " + err.Error())
+		}
+	}
+	if len(ec.CAFile) == 0 && len(ec.CertFile) == 0 && !ec.InsecureSkipVerify {
+		return nil
+	}
+	ec.tlsConfig = &tls.Config{InsecureSkipVerify: ec.InsecureSkipVerify}
+	if len(ec.CAFile) > 0 {
+		pem, err := ioutil.ReadFile(ec.CAFile)
+		if err != nil {
+			return err
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return errors.New("no certificates found in " + ec.CAFile)
+		}
+		ec.tlsConfig.RootCAs = pool
+	}
+	if len(ec.CertFile) > 0 {
+		cert, err := tls.LoadX509KeyPair(ec.CertFile, ec.KeyFile)
+		if err != nil {
+			return err
+		}
+		ec.tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	ec.httpClient = &http.Client{Timeout: defaultTimeout, Transport: &http.Transport{TLSClientConfig: ec.tlsConfig}}
+	return nil
+}
+
+//Sets the credentials and the extra headers on the request headers
+func (ec *EndpointConfig) authorize(h http.Header) {
+	if ec == nil {
+		return
+	}
+	for k, v := range ec.Headers {
+		h.Set(k, v)
+	}
+	switch {
+	case len(ec.jwtSecret) > 0:
+		h.Set("Authorization", "Bearer "+ec.jwtToken(time.Now()))
+	case len(ec.BearerToken) > 0:
+		h.Set("Authorization", "Bearer "+ec.BearerToken)
+	case len(ec.Username) > 0:
+		h.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(ec.Username+":"+ec.Password)))
+	}
+}
+
+//A fresh HS256 token with just the "iat" claim - what geth's authenticated RPC expects
+func (ec *EndpointConfig) jwtToken(now time.Time) string {
+	enc := base64.RawURLEncoding
+	header := enc.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+	claims := enc.EncodeToString([]byte(fmt.Sprintf(`{"iat":%d}`, now.Unix())))
+	mac := hmac.New(sha256.New, ec.jwtSecret)
+	mac.Write([]byte(header + "." + claims))
+	return header + "." + claims + "." + enc.EncodeToString(mac.Sum(nil))
+}
+
+func (ec *EndpointConfig) secure() bool {
+	return ec != nil && ec.Scheme == "https"
+}
+
+//Replaces the endpoint configurations of the client
+func (rpcClient *Client) SetEndpointConfigs(configs map[string]*EndpointConfig) {
+	rpcClient.mx.Lock()
+	defer rpcClient.mx.Unlock()
+	rpcClient.endpoints = configs
+}
+
+//(Re)loads the endpoint configurations from a json file
+func (rpcClient *Client) LoadEndpointConfigs(filename string) error {
+	configs, err := LoadEndpointConfigs(filename)
+	if err != nil {
+		return err
+	}
+	rpcClient.SetEndpointConfigs(configs)
+	log.Printf("Loaded %v endpoint configurations from %s\n", len(configs), filename)
+	return nil
+}
+
+//The configuration for the endpoint: the exact address first, then the host, then the "*" default. Nil if none
+func (rpcClient *Client) endpointConfig(endpoint string) *EndpointConfig {
+	rpcClient.mx.RLock()
+	defer rpcClient.mx.RUnlock()
+	if ec, ok := rpcClient.endpoints[endpoint]; ok {
+		return ec
+	}
+	if host, _, err := net.SplitHostPort(endpoint); err == nil {
+		if ec, ok := rpcClient.endpoints[host]; ok {
+			return ec
+		}
+	}
+	return rpcClient.endpoints["*"]
+}
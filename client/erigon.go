@@ -0,0 +1,78 @@
+package client
+
+func init() {
+	RegisterCollector(erigonCollector{})
+}
+
+type erigonCollector struct{}
+
+func (erigonCollector) Name() string {
+	return "Erigon"
+}
+
+func (erigonCollector) Matches(clientVersion string) bool {
+	return hasPrefixFold(clientVersion, "erigon")
+}
+
+func (erigonCollector) CollectNodeInfo(rpcClient *Client, node *Node, fromScratch bool) error {
+	return rpcClient.collectAdminNodeInfo(erigonCollector{}, node, fromScratch)
+}
+
+func (erigonCollector) PeersMethod() string {
+	return "admin_peers"
+}
+
+func (erigonCollector) ParsePeers(data *CallData) (*PeerArray, error) {
+	return parseGethPeers(data)
+}
+
+func (erigonCollector) TxpoolStatusMethod() string {
+	return "txpool_status"
+}
+
+func (erigonCollector) ParseTxpoolStatus(data *CallData) (*TxpoolStatusSample, error) {
+	return parseGethTxpoolStatus(data)
+}
+
+func (erigonCollector) TxpoolMethod() string {
+	return "txpool_content"
+}
+
+func (erigonCollector) PendingTxsMethod() string {
+	return "txpool_content"
+}
+
+func (erigonCollector) ParsePendingTxs(data *CallData) ([]*Transaction, []string, error) {
+	return parseGethPendingTxs(data)
+}
+
+func (erigonCollector) Methods() [][]string {
+	return ErigonCommsSet
+}
+
+func (erigonCollector) Results() map[string]func() interface{} {
+	return nil
+}
+
+func (n *Node) IsErigon() bool {
+	return erigonCollector{}.Matches(n.ClientVersion)
+}
+
+var ErigonCommsSet = [][]string{GenericRpcEthComms, GenericRpcWeb3Comms, GenericRpcNetComms, ErigonRpcAdminComms, ErigonRpcTxpoolComms,
+	ErigonRpcErigonComms, ErigonRpcDebugComms, ErigonRpcOtsComms, RpcTraceComms}
+
+var ErigonRpcAdminComms = []string{"admin_nodeInfo", "admin_peers"}
+
+var ErigonRpcTxpoolComms = []string{"txpool_content", "txpool_status"}
+
+var ErigonRpcErigonComms = []string{"erigon_blockNumber", "erigon_forks", "erigon_getBalanceChangesInBlock", "erigon_getBlockByTimestamp",
+	"erigon_getBlockReceiptsByBlockHash", "erigon_getHeaderByHash", "erigon_getHeaderByNumber", "erigon_getLatestLogs", "erigon_getLogs",
+	"erigon_getLogsByHash", "erigon_nodeInfo", "eth_chainId", "eth_feeHistory", "eth_getBlockReceipts", "eth_getProof", "eth_maxPriorityFeePerGas"}
+
+var ErigonRpcDebugComms = []string{"debug_accountAt", "debug_accountRange", "debug_getModifiedAccountsByHash", "debug_getModifiedAccountsByNumber",
+	"debug_storageRangeAt", "debug_traceBlockByHash", "debug_traceBlockByNumber", "debug_traceCall", "debug_traceCallMany", "debug_traceTransaction"}
+
+//Otterscan
+var ErigonRpcOtsComms = []string{"ots_getApiLevel", "ots_getBlockDetails", "ots_getBlockTransactions", "ots_getContractCreator",
+	"ots_getInternalOperations", "ots_getTransactionBySenderAndNonce", "ots_getTransactionError", "ots_hasCode", "ots_searchTransactionsAfter",
+	"ots_searchTransactionsBefore", "ots_traceTransaction"}
@@ -0,0 +1,259 @@
+package client
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+//The block explorer. Blocks, transactions, receipts and accounts are read from any node of the model,
+//the access node if none is chosen
+
+const latestBlocksShown = 10
+
+const SearchBlock = "block"
+const SearchTx = "tx"
+const SearchAccount = "account"
+
+var hash32 = regexp.MustCompile("^0x[0-9a-fA-F]{64}$")
+var shortHex = regexp.MustCompile("^0x[0-9a-fA-F]{1,16}$")
+
+//What an explorer page shows, only some of it set for each page
+type ExplorerPage struct {
+	Node    *Node
+	Block   *Block
+	Head    HexString //the node's latest block, to tell if there is a next one
+	Blocks  []*Block  //the latest blocks, for the landing page
+	Tx      *Transaction
+	Receipt *Receipt
+	Account *Account
+}
+
+//The number of the block after the shown one, if the node has it
+func (p *ExplorerPage) NextBlock() HexString {
+	if p.Block == nil || p.Block.Number >= p.Head {
+		return -1
+	}
+	return p.Block.Number + 1
+}
+
+type Account struct {
+	Address  string
+	Balance  *BigHex
+	Nonce    HexString
+	CodeSize int //bytes, 0 for an externally owned account
+}
+
+func (a *Account) IsContract() bool {
+	return a.CodeSize > 0
+}
+
+//Finds the node by its ID, its RPC address, or its name if no other node has the same
+func (bcn *BlockchainNet) FindNode(key string) (*Node, bool) {
+	if n, ok := bcn.Nodes[NodeID(key)]; ok {
+		return n, true
+	}
+	var found *Node
+	for _, n := range bcn.Nodes {
+		if n.RPCAddress == key {
+			return n, true
+		}
+		if n.ShortName == key {
+			if found != nil {
+				return nil, false
+			}
+			found = n
+		}
+	}
+	return found, found != nil
+}
+
+//The node to query, by its ID, address or name; the access node for an empty key
+func (rpcClient *Client) ExplorerNode(key string) (*Node, error) {
+	bcn := rpcClient.NetModel()
+	if len(key) == 0 {
+		key = string(bcn.AccessNodeID)
+	}
+	n, ok := bcn.FindNode(key)
+	if !ok {
+		return nil, errors.New("unknown node " + key)
+	}
+	return n, nil
+}
+
+//Calls the node and returns the parsed result. An RPC error comes back as the error
+func (rpcClient *Client) explorerCall(node *Node, method string, params ...interface{}) (interface{}, error) {
+	data := rpcClient.newNodeCall(node, method, params...)
+	if err := rpcClient.actualRpcCall(data); err != nil {
+		return nil, err
+	}
+	if data.Response.Error != nil {
+		return nil, *data.Response.Error
+	}
+	if !data.Parsed {
+		return nil, errors.New("unexpected result of " + method)
+	}
+	return data.ParsedResult, nil
+}
+
+//The block by its number (decimal or hex), hash or tag (latest, earliest, pending), with the full transactions
+func (rpcClient *Client) GetBlock(node *Node, ref string) (*Block, error) {
+	ref = strings.TrimSpace(ref)
+	var res interface{}
+	var err error
+	switch {
+	case hash32.MatchString(ref):
+		res, err = rpcClient.explorerCall(node, "eth_getBlockByHash", ref, true)
+	default:
+		tag, terr := blockTag(ref)
+		if terr != nil {
+			return nil, terr
+		}
+		res, err = rpcClient.explorerCall(node, "eth_getBlockByNumber", tag, true)
+	}
+	if err != nil {
+		return nil, err
+	}
+	b := res.(*Block)
+	if len(b.Hash) == 0 {
+		return nil, errors.New(node.ShortName + " has no block " + ref)
+	}
+	return b, nil
+}
+
+//The eth_getBlockByNumber parameter for a number or a tag
+func blockTag(ref string) (string, error) {
+	switch {
+	case len(ref) == 0:
+		return "latest", nil
+	case ref == "latest" || ref == "earliest" || ref == "pending":
+		return ref, nil
+	case shortHex.MatchString(ref):
+		return strings.ToLower(ref), nil
+	}
+	n, err := strconv.ParseUint(ref, 10, 63)
+	if err != nil {
+		return "", errors.New("not a block number, hash or tag: " + ref)
+	}
+	return HexString(n).hex(), nil
+}
+
+//The number of the latest block of the node
+func (rpcClient *Client) BlockNumber(node *Node) (HexString, error) {
+	res, err := rpcClient.explorerCall(node, "eth_blockNumber")
+	if err != nil {
+		return 0, err
+	}
+	return res.(*BlockNumberSample).BlockNumber, nil
+}
+
+//The latest blocks of the node, without their transactions, the newest first
+func (rpcClient *Client) LatestBlocks(node *Node) ([]*Block, error) {
+	head, err := rpcClient.GetBlock(node, "latest")
+	if err != nil {
+		return nil, err
+	}
+	blocks := []*Block{head}
+	var calls []*CallData
+	for n := head.Number - 1; n >= 0 && n > head.Number-latestBlocksShown; n-- {
+		calls = append(calls, rpcClient.newNodeCall(node, "eth_getBlockByNumber", n.hex(), false))
+	}
+	for i, err := range rpcClient.callAll(calls...) {
+		if err != nil {
+			return blocks, err
+		}
+		if b, ok := calls[i].ParsedResult.(*Block); ok && calls[i].Parsed && len(b.Hash) > 0 {
+			blocks = append(blocks, b)
+		}
+	}
+	return blocks, nil
+}
+
+//The transaction and, once it is mined, its receipt
+func (rpcClient *Client) GetTransaction(node *Node, hash string) (*Transaction, *Receipt, error) {
+	hash = strings.TrimSpace(hash)
+	if !hash32.MatchString(hash) {
+		return nil, nil, errors.New("not a transaction hash: " + hash)
+	}
+	res, err := rpcClient.explorerCall(node, "eth_getTransactionByHash", hash)
+	if err != nil {
+		return nil, nil, err
+	}
+	tx := res.(*Transaction)
+	if len(tx.Hash) == 0 {
+		return nil, nil, errors.New(node.ShortName + " does not know the transaction " + hash)
+	}
+	if tx.IsPending() {
+		return tx, nil, nil
+	}
+	r, err := rpcClient.GetReceipt(node, hash)
+	return tx, r, err
+}
+
+func (rpcClient *Client) GetReceipt(node *Node, hash string) (*Receipt, error) {
+	hash = strings.TrimSpace(hash)
+	if !hash32.MatchString(hash) {
+		return nil, errors.New("not a transaction hash: " + hash)
+	}
+	res, err := rpcClient.explorerCall(node, "eth_getTransactionReceipt", hash)
+	if err != nil {
+		return nil, err
+	}
+	r := res.(*Receipt)
+	if len(r.TransactionHash) == 0 {
+		return nil, errors.New(node.ShortName + " has no receipt of " + hash)
+	}
+	return r, nil
+}
+
+//The balance, nonce and code of the address at the latest block
+func (rpcClient *Client) GetAccount(node *Node, address string) (*Account, error) {
+	address = strings.TrimSpace(address)
+	if !validatorAddress.MatchString(address) {
+		return nil, errors.New("not a valid address: " + address)
+	}
+	balance := rpcClient.newNodeCall(node, "eth_getBalance", address, "latest")
+	nonce := rpcClient.newNodeCall(node, "eth_getTransactionCount", address, "latest")
+	code := rpcClient.newNodeCall(node, "eth_getCode", address, "latest")
+	calls := []*CallData{balance, nonce, code}
+	for i, err := range rpcClient.callAll(calls...) {
+		if err == nil && calls[i].Response.Error != nil {
+			err = *calls[i].Response.Error
+		}
+		if err == nil && !calls[i].Parsed {
+			err = errors.New("unexpected result of " + calls[i].Command.Method)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	acc := &Account{Address: address, Balance: balance.ParsedResult.(*BigHex), Nonce: *nonce.ParsedResult.(*HexString)}
+	if c := string(*code.ParsedResult.(*StringResult)); len(c) > 2 {
+		acc.CodeSize = (len(c) - 2) / 2
+	}
+	return acc, nil
+}
+
+//Tells what the query is: a block number, hash or tag, a transaction hash or an address.
+//Returns the kind (SearchBlock, SearchTx or SearchAccount) and the key to look it up by
+func (rpcClient *Client) Search(node *Node, query string) (kind string, key string, err error) {
+	query = strings.TrimSpace(query)
+	switch {
+	case validatorAddress.MatchString(query):
+		return SearchAccount, query, nil
+	case hash32.MatchString(query):
+		//A hash may be of a block or of a transaction
+		if _, berr := rpcClient.GetBlock(node, query); berr == nil {
+			return SearchBlock, query, nil
+		}
+		if tx, _, _ := rpcClient.GetTransaction(node, query); tx != nil {
+			return SearchTx, query, nil
+		}
+		return "", "", errors.New(node.ShortName + " knows no block or transaction " + query)
+	}
+	if _, err := blockTag(query); err != nil {
+		return "", "", errors.New("not a block number, hash, transaction hash or address: " + query)
+	}
+	return SearchBlock, query, nil
+}
@@ -0,0 +1,252 @@
+package client
+
+import (
+	"errors"
+	"math/big"
+	"sync"
+	"time"
+)
+
+//EIP-1559 fee monitoring. The fees of the latest blocks are read by eth_feeHistory from one node, as the nodes on the
+//same chain agree on them: the base fee, the share of the gas limit used and the priority fees paid at the percentiles
+//of the gas used in the block. The blocks read are kept per node, so that each reading asks only for the blocks since
+//the last one from the node, and the views of different nodes do not drop the blocks of each other. The blocks kept
+//are consecutive: if the new ones do not follow them, the old ones are dropped.
+//The base fee and the gas used of the head also go into the series of the node, for the charts and the store
+
+const feeHistoryLength = 256 //blocks kept
+const fullBlockRatio = 0.95  //of the gas limit used, for a block to count as full
+
+const MetricBaseFee = "basefee" //gwei
+const MetricGasUsed = "gasused" //percent of the gas limit
+
+//Of the priority fees paid in a block, weighted by the gas used
+var FeePercentiles = []float64{10, 50, 90}
+
+//What eth_feeHistory returns
+type FeeHistory struct {
+	OldestBlock   HexString   `json:"oldestBlock"`
+	BaseFeePerGas []*BigHex   `json:"baseFeePerGas"` //one more than the blocks: the last is of the block to come
+	GasUsedRatio  []float64   `json:"gasUsedRatio"`
+	Reward        [][]*BigHex `json:"reward"` //at the percentiles asked for
+}
+
+type BlockFees struct {
+	Number       HexString
+	BaseFee      *BigHex
+	GasUsedRatio float64
+	Rewards      []*BigHex //the priority fees at the FeePercentiles
+}
+
+func (bf BlockFees) IsEmpty() bool {
+	return bf.GasUsedRatio == 0
+}
+
+func (bf BlockFees) IsFull() bool {
+	return bf.GasUsedRatio >= fullBlockRatio
+}
+
+func (bf BlockFees) GasUsedPercent() float64 {
+	return 100 * bf.GasUsedRatio
+}
+
+type FeeReport struct {
+	Node        *Node
+	Sampled     MyTime
+	Percentiles []float64
+	Blocks      []BlockFees //the latest first
+	NextBaseFee *BigHex     //of the block to come
+	Empty       int         //of the Blocks
+	Full        int
+	FullStreak  int     //the latest blocks, all of them full
+	AvgGasUsed  float64 //percent of the gas limit
+}
+
+func (fr *FeeReport) EmptyPercent() float64 {
+	if len(fr.Blocks) == 0 {
+		return 0
+	}
+	return 100 * float64(fr.Empty) / float64(len(fr.Blocks))
+}
+
+func (fr *FeeReport) FullPercent() float64 {
+	if len(fr.Blocks) == 0 {
+		return 0
+	}
+	return 100 * float64(fr.Full) / float64(len(fr.Blocks))
+}
+
+//The blocks read so far, of a single node
+type feeTracker struct {
+	mx      sync.Mutex
+	node    *Node
+	blocks  []BlockFees //the oldest first
+	next    *BigHex
+	sampled MyTime
+}
+
+//The trackers of the nodes read
+type feeTrackers struct {
+	mx     sync.Mutex
+	byNode map[NodeID]*feeTracker
+	last   *feeTracker //the last one read
+}
+
+func (fts *feeTrackers) tracker(id NodeID) *feeTracker {
+	fts.mx.Lock()
+	defer fts.mx.Unlock()
+	if fts.byNode == nil {
+		fts.byNode = map[NodeID]*feeTracker{}
+	}
+	ft, ok := fts.byNode[id]
+	if !ok {
+		ft = &feeTracker{}
+		fts.byNode[id] = ft
+	}
+	return ft
+}
+
+//The node for the watchdog to read the fees from: the access node, or the reachable node with the highest block
+//if the access node is down
+func (rpcClient *Client) FeeNode() (*Node, error) {
+	bcn := rpcClient.NetModel()
+	if n, ok := bcn.Nodes[bcn.AccessNodeID]; ok && n.IsReachable() {
+		return n, nil
+	}
+	var best *Node
+	for _, n := range bcn.sortedNodes() {
+		if n.IsReachable() && len(n.RPCAddress) > 0 && (best == nil || n.blockNumber() > best.blockNumber()) {
+			best = n
+		}
+	}
+	if best == nil {
+		return nil, errors.New("no reachable node to read the fees from")
+	}
+	return best, nil
+}
+
+//Reads the fees of the blocks since the last reading from the node and returns the report on all the blocks kept for the node.
+//The head is asked of the node, as the block number of the model may be behind
+func (rpcClient *Client) TrackFees(node *Node) (*FeeReport, error) {
+	ft := rpcClient.fees.tracker(node.ID)
+	ft.mx.Lock()
+	defer ft.mx.Unlock()
+	ft.node = node
+	latest, err := rpcClient.BlockNumber(node)
+	if err != nil {
+		return nil, err
+	}
+	count := HexString(feeHistoryLength)
+	if n := len(ft.blocks); n > 0 {
+		//a few blocks back as well, in case of a reorg
+		count = latest - ft.blocks[n-1].Number + forkCheckDepth
+		if count < 1 {
+			count = 1
+		}
+		if count > feeHistoryLength {
+			count = feeHistoryLength
+		}
+	}
+	if count > latest+1 {
+		count = latest + 1
+	}
+	res, err := rpcClient.explorerCall(node, "eth_feeHistory", count.hex(), latest.hex(), FeePercentiles)
+	if err != nil {
+		return nil, err
+	}
+	fh := res.(*FeeHistory)
+	if len(fh.GasUsedRatio) == 0 || len(fh.BaseFeePerGas) <= len(fh.GasUsedRatio) {
+		return nil, errors.New(node.ShortName + " returned no fee history, the chain may be older than London")
+	}
+	kept := ft.blocks[:0:0]
+	for _, b := range ft.blocks {
+		if b.Number < fh.OldestBlock {
+			kept = append(kept, b)
+		}
+	}
+	//The node may return fewer blocks than asked for. The blocks kept must not leave a gap before the new ones
+	if n := len(kept); n > 0 && kept[n-1].Number != fh.OldestBlock-1 {
+		kept = kept[:0]
+	}
+	for i, ratio := range fh.GasUsedRatio {
+		bf := BlockFees{Number: fh.OldestBlock + HexString(i), BaseFee: fh.BaseFeePerGas[i], GasUsedRatio: ratio}
+		if i < len(fh.Reward) {
+			bf.Rewards = fh.Reward[i]
+		}
+		kept = append(kept, bf)
+	}
+	if len(kept) > feeHistoryLength {
+		kept = kept[len(kept)-feeHistoryLength:]
+	}
+	ft.blocks = kept
+	ft.next = fh.BaseFeePerGas[len(fh.BaseFeePerGas)-1]
+	ft.sampled = MyTime(time.Now())
+	head := kept[len(kept)-1]
+	rpcClient.recordFees(node.ID, Point{ft.sampled, gwei(head.BaseFee)}, Point{ft.sampled, head.GasUsedPercent()})
+	rpcClient.fees.mx.Lock()
+	rpcClient.fees.last = ft
+	rpcClient.fees.mx.Unlock()
+	return ft.report(), nil
+}
+
+//The report on the blocks read last, of whichever node, nil if none has been read
+func (rpcClient *Client) LastFees() *FeeReport {
+	rpcClient.fees.mx.Lock()
+	ft := rpcClient.fees.last
+	rpcClient.fees.mx.Unlock()
+	if ft == nil {
+		return nil
+	}
+	ft.mx.Lock()
+	defer ft.mx.Unlock()
+	if len(ft.blocks) == 0 {
+		return nil
+	}
+	return ft.report()
+}
+
+//The caller holds the mx
+func (ft *feeTracker) report() *FeeReport {
+	fr := &FeeReport{Node: ft.node, Sampled: ft.sampled, Percentiles: FeePercentiles, NextBaseFee: ft.next}
+	streak := true
+	var used float64
+	for i := len(ft.blocks) - 1; i >= 0; i-- {
+		b := ft.blocks[i]
+		fr.Blocks = append(fr.Blocks, b)
+		used += b.GasUsedPercent()
+		if b.IsEmpty() {
+			fr.Empty++
+		}
+		if b.IsFull() {
+			fr.Full++
+		}
+		//the streak of the consecutive blocks only
+		streak = streak && b.IsFull() && (i == len(ft.blocks)-1 || b.Number == ft.blocks[i+1].Number-1)
+		if streak {
+			fr.FullStreak++
+		}
+	}
+	fr.AvgGasUsed = used / float64(len(ft.blocks))
+	return fr
+}
+
+//Adds the base fee and the gas used of the head to the series of the node
+func (rpcClient *Client) recordFees(id NodeID, baseFee, gasUsed Point) {
+	ss := &rpcClient.series
+	ss.mx.Lock()
+	defer ss.mx.Unlock()
+	if ss.add(id, MetricBaseFee, baseFee) {
+		rpcClient.storeSample(id, MetricBaseFee, baseFee)
+	}
+	if ss.add(id, MetricGasUsed, gasUsed) {
+		rpcClient.storeSample(id, MetricGasUsed, gasUsed)
+	}
+}
+
+func gwei(b *BigHex) float64 {
+	if b == nil {
+		return 0
+	}
+	f, _ := new(big.Float).Quo(new(big.Float).SetInt(&b.Int), big.NewFloat(1e9)).Float64()
+	return f
+}
@@ -0,0 +1,133 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+//A chain of which every third block is full, with the head moved by the test. The fee history is capped
+//at maxCount blocks, as the nodes do, if set
+type feeChain struct {
+	mx       sync.Mutex
+	head     HexString
+	maxCount HexString
+}
+
+func (fc *feeChain) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	fc.mx.Lock()
+	defer fc.mx.Unlock()
+	body, _ := ioutil.ReadAll(r.Body)
+	var com EthCommand
+	json.Unmarshal(body, &com)
+	var result interface{}
+	switch com.Method {
+	case "eth_blockNumber":
+		result = fc.head.hex()
+	case "eth_feeHistory":
+		count, _ := strconv.ParseUint(strings.TrimPrefix(com.Params[0].(string), "0x"), 16, 64)
+		newest, _ := strconv.ParseUint(strings.TrimPrefix(com.Params[1].(string), "0x"), 16, 64)
+		if fc.maxCount > 0 && HexString(count) > fc.maxCount {
+			count = uint64(fc.maxCount)
+		}
+		fh := map[string]interface{}{"oldestBlock": HexString(newest - count + 1).hex()}
+		var fees []string
+		var ratios []float64
+		for n := newest - count + 1; n <= newest; n++ {
+			fees = append(fees, HexString(1e9).hex())
+			ratios = append(ratios, float64(n%3/2))
+		}
+		fh["baseFeePerGas"] = append(fees, HexString(1e9).hex())
+		fh["gasUsedRatio"] = ratios
+		result = fh
+	}
+	res, _ := json.Marshal(result)
+	fmt.Fprintf(w, `{"jsonrpc": "2.0", "id": %d, "result": %s}`, com.Id, res)
+}
+
+func (fc *feeChain) setHead(head HexString) {
+	fc.mx.Lock()
+	fc.head = head
+	fc.mx.Unlock()
+}
+
+func newFeeTest(t *testing.T, fc *feeChain) (*Client, *Node) {
+	server := httptest.NewServer(fc)
+	t.Cleanup(server.Close)
+	endpoint := strings.TrimPrefix(server.URL, "http://")
+	rpcClient, _ := NewClient(endpoint, false, false)
+	node := &Node{ID: "feenode", ShortName: "feenode", RPCAddress: endpoint}
+	return rpcClient, node
+}
+
+func checkConsecutive(t *testing.T, fr *FeeReport, head HexString, blocks int) {
+	if len(fr.Blocks) != blocks {
+		t.Errorf("%d blocks kept, expected %d", len(fr.Blocks), blocks)
+	}
+	for i, b := range fr.Blocks {
+		if b.Number != head-HexString(i) {
+			t.Fatalf("block %d at %d of the report, expected %d", b.Number, i, head-HexString(i))
+		}
+	}
+}
+
+func TestTrackFeesStaleModel(t *testing.T) {
+	fc := &feeChain{head: 100}
+	rpcClient, node := newFeeTest(t, fc)
+	//the model saw the block 100, and was not probed since
+	node.LastBlockNumberSample = &BlockNumberSample{BlockNumber: 100}
+	fr, err := rpcClient.TrackFees(node)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkConsecutive(t, fr, 100, 101)
+	fc.setHead(150)
+	if fr, err = rpcClient.TrackFees(node); err != nil {
+		t.Fatal(err)
+	}
+	checkConsecutive(t, fr, 150, 151)
+	//further than the blocks kept
+	fc.setHead(1000)
+	if fr, err = rpcClient.TrackFees(node); err != nil {
+		t.Fatal(err)
+	}
+	checkConsecutive(t, fr, 1000, feeHistoryLength)
+}
+
+func TestTrackFeesCappedHistory(t *testing.T) {
+	fc := &feeChain{head: 100, maxCount: 8}
+	rpcClient, node := newFeeTest(t, fc)
+	if _, err := rpcClient.TrackFees(node); err != nil {
+		t.Fatal(err)
+	}
+	fc.setHead(104)
+	fr, err := rpcClient.TrackFees(node)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkConsecutive(t, fr, 104, 12)
+	//the node does not return all the blocks since the last reading
+	fc.setHead(200)
+	if fr, err = rpcClient.TrackFees(node); err != nil {
+		t.Fatal(err)
+	}
+	checkConsecutive(t, fr, 200, 8)
+}
+
+func TestFeeReportGap(t *testing.T) {
+	full := BlockFees{GasUsedRatio: 1}
+	ft := &feeTracker{}
+	for _, n := range []HexString{5, 6, 7, 10, 11} {
+		full.Number = n
+		ft.blocks = append(ft.blocks, full)
+	}
+	if fr := ft.report(); fr.FullStreak != 2 || fr.Full != 5 {
+		t.Errorf("a streak of %d, %d full blocks, expected 2 and 5", fr.FullStreak, fr.Full)
+	}
+}
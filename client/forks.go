@@ -0,0 +1,245 @@
+package client
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+//Fork detection. The block numbers alone do not tell two halves of a partitioned network from a healthy one,
+//so the nodes are compared by the hashes of their blocks. Every node is asked for the block a little below its own head
+//and the most advanced node for the block at the same height; the nodes it disagrees with are compared the same way
+//among themselves, until every node is placed on a branch
+
+//Blocks below the head compared, so that a reorg of the tip is not taken for a fork
+const forkCheckDepth = 2
+
+//The nodes following the same chain
+type ForkBranch struct {
+	Reference NodeID //the most advanced node of the branch
+	Head      HexString
+	Hash      string //of the Reference's block at Height
+	Height    HexString
+	Nodes     []NodeID
+	ForkPoint HexString //the last block shared with the main branch, -1 if there is none
+}
+
+type ForkReport struct {
+	Branches  []*ForkBranch //the main branch, the one most nodes follow, comes first
+	Unchecked []NodeID      //reachable nodes which could not be compared
+	Sampled   MyTime
+}
+
+func (fr *ForkReport) IsForked() bool {
+	return len(fr.Branches) > 1
+}
+
+//The branch the node follows, nil if it has not been compared
+func (fr *ForkReport) BranchOf(id NodeID) *ForkBranch {
+	for _, b := range fr.Branches {
+		if containsNodeID(b.Nodes, id) {
+			return b
+		}
+	}
+	return nil
+}
+
+//Returns the result of the latest DetectForks, nil if none has run
+func (rpcClient *Client) LastForkReport() *ForkReport {
+	rpcClient.mx.RLock()
+	defer rpcClient.mx.RUnlock()
+	return rpcClient.forkReport
+}
+
+//Compares the chains of the reachable nodes which are not syncing. The nodes are compared once per published model,
+//the views and the watchdog asking in between get the report already made
+func (rpcClient *Client) DetectForks() *ForkReport {
+	bcn := rpcClient.NetModel()
+	rpcClient.mx.RLock()
+	last, model := rpcClient.forkReport, rpcClient.forkModel
+	rpcClient.mx.RUnlock()
+	if last != nil && model == bcn {
+		return last
+	}
+	rep := &ForkReport{Sampled: MyTime(time.Now())}
+	var nodes []*Node
+	for _, n := range bcn.sortedNodes() {
+		if !n.IsReachable() || n.LastBlockNumberSample == nil || (n.SyncStatus != nil && n.SyncStatus.Syncing) {
+			continue
+		}
+		nodes = append(nodes, n)
+	}
+	own := rpcClient.ownCheckHashes(nodes)
+	var remaining []*Node
+	for _, n := range nodes {
+		if _, ok := own[n.ID]; ok {
+			remaining = append(remaining, n)
+		} else {
+			rep.Unchecked = append(rep.Unchecked, n.ID)
+		}
+	}
+	for len(remaining) > 0 {
+		ref := remaining[0]
+		for _, n := range remaining {
+			if n.blockNumber() > ref.blockNumber() {
+				ref = n
+			}
+		}
+		var heights []HexString
+		for _, n := range remaining {
+			heights = append(heights, checkHeight(n))
+		}
+		refHashes, err := rpcClient.blockHashes(ref, heights...)
+		if err != nil {
+			rep.Unchecked = append(rep.Unchecked, ref.ID)
+			remaining = removeNode(remaining, ref)
+			continue
+		}
+		branch := &ForkBranch{Reference: ref.ID, Head: ref.blockNumber(), Height: checkHeight(ref), Hash: own[ref.ID]}
+		var rest []*Node
+		for _, n := range remaining {
+			if n == ref || refHashes[checkHeight(n)] == own[n.ID] {
+				branch.Nodes = append(branch.Nodes, n.ID)
+			} else {
+				rest = append(rest, n)
+			}
+		}
+		rep.Branches = append(rep.Branches, branch)
+		remaining = rest
+	}
+	sort.SliceStable(rep.Branches, func(i, j int) bool { return len(rep.Branches[i].Nodes) > len(rep.Branches[j].Nodes) })
+	if rep.IsForked() {
+		main := rep.Branches[0]
+		for _, b := range rep.Branches[1:] {
+			b.ForkPoint = rpcClient.forkPoint(bcn.Nodes[main.Reference], bcn.Nodes[b.Reference])
+		}
+	}
+	rpcClient.mx.Lock()
+	rpcClient.forkReport, rpcClient.forkModel = rep, bcn
+	rpcClient.mx.Unlock()
+	return rep
+}
+
+func checkHeight(n *Node) HexString {
+	h := n.blockNumber() - forkCheckDepth
+	if h < 0 {
+		h = 0
+	}
+	return h
+}
+
+//The hash of every node's block at its checkHeight, asked over the worker pool
+func (rpcClient *Client) ownCheckHashes(nodes []*Node) map[NodeID]string {
+	hashes := map[NodeID]string{}
+	if len(nodes) == 0 {
+		return hashes
+	}
+	mx := sync.Mutex{}
+	jobs := make(chan *Node)
+	wg := sync.WaitGroup{}
+	for i := 0; i < rpcClient.workerCount(len(nodes)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := range jobs {
+				h, err := rpcClient.blockHashes(n, checkHeight(n))
+				if err != nil {
+					rpcClient.log(n.ShortName + ": " + err.Error())
+					continue
+				}
+				mx.Lock()
+				hashes[n.ID] = h[checkHeight(n)]
+				mx.Unlock()
+			}
+		}()
+	}
+	for _, n := range nodes {
+		jobs <- n
+	}
+	close(jobs)
+	wg.Wait()
+	return hashes
+}
+
+//The hashes of the node's blocks at the heights, in one round trip if the node takes batches
+func (rpcClient *Client) blockHashes(node *Node, heights ...HexString) (map[HexString]string, error) {
+	var calls []*CallData
+	var asked []HexString
+	for _, h := range heights {
+		if containsHeight(asked, h) {
+			continue
+		}
+		asked = append(asked, h)
+		calls = append(calls, rpcClient.newNodeCall(node, "eth_getBlockByNumber", h.hex(), false))
+	}
+	hashes := map[HexString]string{}
+	for i, err := range rpcClient.callAll(calls...) {
+		if err == nil && calls[i].Response.Error != nil {
+			err = *calls[i].Response.Error
+		}
+		if err == nil && (!calls[i].Parsed || len(calls[i].ParsedResult.(*Block).Hash) == 0) { //null if the node has no such block
+			err = errors.New("no block " + asked[i].hex())
+		}
+		if err != nil {
+			return nil, err
+		}
+		hashes[asked[i]] = calls[i].ParsedResult.(*Block).Hash
+	}
+	return hashes, nil
+}
+
+//Binary search for the highest block the two nodes agree on, -1 if they do not even share the genesis
+func (rpcClient *Client) forkPoint(a, b *Node) HexString {
+	lo, hi := HexString(-1), checkHeight(a)
+	if checkHeight(b) < hi {
+		hi = checkHeight(b)
+	}
+	if same, err := rpcClient.sameBlock(a, b, hi); err != nil || same {
+		return hi
+	}
+	for hi-lo > 1 {
+		mid := lo + (hi-lo)/2
+		same, err := rpcClient.sameBlock(a, b, mid)
+		if err != nil {
+			rpcClient.log(err.Error())
+			return lo
+		}
+		if same {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return lo
+}
+
+func (rpcClient *Client) sameBlock(a, b *Node, height HexString) (bool, error) {
+	ha, err := rpcClient.blockHashes(a, height)
+	if err != nil {
+		return false, err
+	}
+	hb, err := rpcClient.blockHashes(b, height)
+	if err != nil {
+		return false, err
+	}
+	return ha[height] == hb[height], nil
+}
+
+func containsHeight(heights []HexString, h HexString) bool {
+	for _, x := range heights {
+		if x == h {
+			return true
+		}
+	}
+	return false
+}
+
+func removeNode(nodes []*Node, node *Node) (rest []*Node) {
+	for _, n := range nodes {
+		if n != node {
+			rest = append(rest, n)
+		}
+	}
+	return
+}
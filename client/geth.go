@@ -0,0 +1,155 @@
+package client
+
+import (
+	"errors"
+	"log"
+	"strings"
+)
+
+func init() {
+	RegisterCollector(gethCollector{})
+}
+
+type gethCollector struct{}
+
+func (gethCollector) Name() string {
+	return "Geth"
+}
+
+func (gethCollector) Matches(clientVersion string) bool {
+	return strings.HasPrefix(clientVersion, "Geth")
+}
+
+func (gethCollector) CollectNodeInfo(rpcClient *Client, node *Node, fromScratch bool) error {
+	nodeInfo := rpcClient.newNodeCall(node, "admin_nodeInfo")
+	txpool := rpcClient.newNodeCall(node, gethCollector{}.TxpoolStatusMethod())
+	syncing := rpcClient.newNodeCall(node, "eth_syncing")
+	blockNo := rpcClient.newNodeCall(node, "eth_blockNumber")
+	peers := rpcClient.newNodeCall(node, "admin_peers")
+	calls := []*CallData{txpool, syncing, blockNo, peers}
+	if fromScratch {
+		calls = append([]*CallData{nodeInfo}, calls...)
+	}
+	//One round trip if the node accepts batches
+	errs := rpcClient.callAll(calls...)
+	if fromScratch {
+		if errs[0] != nil {
+			return errs[0]
+		}
+		errs = errs[1:]
+		ni, ok := nodeInfo.ParsedResult.(*NodeInfo)
+		if !ok {
+			log.Printf("expected %T got %T", ni, nodeInfo.ParsedResult)
+			return errors.New("not ok parsing the root node info")
+		}
+		FillNodeFromNodeInfo_Geth(node, ni)
+		node.isFromPeer = false
+	}
+	//Get the txpool status, the txpool api may be disabled
+	node.applyTxpoolStatus(gethCollector{}, txpool, errs[0])
+
+	if errs[1] == nil && syncing.Parsed {
+		node.SyncStatus = syncing.ParsedResult.(*SyncStatus)
+	}
+
+	//Get the BlockNumber
+	err := node.applyBlockNumber(blockNo, errs[2])
+
+	//Get peers
+	err = node.applyPeers(peers, errs[3])
+
+	return err
+}
+
+func (gethCollector) PeersMethod() string {
+	return "admin_peers"
+}
+
+func (gethCollector) ParsePeers(data *CallData) (*PeerArray, error) {
+	return parseGethPeers(data)
+}
+
+func (gethCollector) TxpoolStatusMethod() string {
+	return "txpool_status"
+}
+
+func (gethCollector) ParseTxpoolStatus(data *CallData) (*TxpoolStatusSample, error) {
+	return parseGethTxpoolStatus(data)
+}
+
+func (gethCollector) TxpoolMethod() string {
+	return "txpool_inspect"
+}
+
+func (gethCollector) PendingTxsMethod() string {
+	return "txpool_content"
+}
+
+func (gethCollector) ParsePendingTxs(data *CallData) ([]*Transaction, []string, error) {
+	return parseGethPendingTxs(data)
+}
+
+func (gethCollector) Methods() [][]string {
+	return GethCommsSet
+}
+
+func (gethCollector) Results() map[string]func() interface{} {
+	return nil
+}
+
+func (n *Node) IsGeth() bool {
+	return strings.HasPrefix(n.ClientVersion, "Geth")
+}
+
+// Geth specific
+// Extract the short name from the NodeAddress name
+// assuming the name is of the form: "Geth/miner3/v1.7.2-stable/linux-amd64/go1.9.2"
+func (n Node) getGethShortName() (string, bool) {
+	if !n.IsGeth() {
+		return "", false
+	}
+	parts := strings.Split(n.FullName, "/")
+	if len(parts) > 1 {
+		return parts[1], true
+
+	}
+	return "unknown", false
+}
+
+//Geth specific
+func FillNodeFromNodeInfo_Geth(n *Node, ni *NodeInfo) {
+	n.ID = nodeID(ni.ID, ni.Enode)
+	n.ThisNodeInfo = *ni
+	n.FullName = ni.Name
+	n.Enode = ni.Enode
+	n.ShortName, _ = n.getGethShortName()
+	n.setReachable(true) //This is based on the assumption that the node info has been just obtained
+	return
+}
+
+var GethCommsSet = [][]string{GethRpcMinerComms, GethRpcTxpoolComms, GethRpcAdminComms, GethRpcOtherComms, GenericRpcEthComms, GenericRpcWeb3Comms, GenericRpcNetComms,
+	GethRpcCliqueComms, QuorumRpcIstanbulComms}
+
+var GethRpcOtherComms = []string{"debug_backtraceAt", "personal_ecRecover",
+	"debug_blockProfile", "miner_setGasPrice", "personal_importRawKey", "txpool_inspect",
+	"debug_cpuProfile", "miner_start", "personal_listAccounts", "txpool_status", "debug_dumpBlock", "miner_stop", "personal_lockAccount",
+	"debug_gcStats", "miner_getHashrate", "personal_newAccount", "debug_getBlockRlp", "miner_aetEtherbase", "personal_unlockAccount",
+	"debug_goTrace", "personal_sendTransaction", "debug_memStats", "personal_sign", "debug_seedHashsign",
+	"db_putString", "db_getString", "db_putHex", "db_getHex", "shh_post", "shh_version", "shh_newIdentity", "shh_hasIdentity", "shh_newGroup",
+	"shh_addToGroup", "shh_newFilter", "shh_uninstallFilter", "shh_getFilterChanges", "shh_getMessages",
+	"eth_feeHistory", "eth_maxPriorityFeePerGas"}
+
+var GethRpcCliqueComms = []string{"clique_discard", "clique_getSigner", "clique_getSigners", "clique_getSignersAtHash", "clique_getSnapshot",
+	"clique_getSnapshotAtHash", "clique_proposals", "clique_propose", "clique_status"}
+
+//GoQuorum reports itself as Geth
+var QuorumRpcIstanbulComms = []string{"istanbul_candidates", "istanbul_discard", "istanbul_getSignersFromBlock", "istanbul_getSignersFromBlockByHash",
+	"istanbul_getSnapshot", "istanbul_getSnapshotAtHash", "istanbul_getValidators", "istanbul_getValidatorsAtHash", "istanbul_isValidator",
+	"istanbul_nodeAddress", "istanbul_propose", "istanbul_status"}
+
+var GethRpcMinerComms = []string{"miner_setExtra", "miner_setGasPrice", "miner_start", "miner_stop", "miner_getHashrate", "miner_getEtherbase"}
+
+var GethRpcTxpoolComms = []string{"txpool_content", "txpool_inspect", "txpool_status"}
+
+var GethRpcAdminComms = []string{"admin_addPeer", "admin_datadir", "admin_nodeInfo", "admin_peers", "admin_setSolc",
+	"admin_startRPC", "admin_startWS", "admin_stopRPC", "admin_stopWS"}
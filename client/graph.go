@@ -0,0 +1,173 @@
+package client
+
+import (
+	"sort"
+	"sync"
+)
+
+//Partition analysis of the peer graph. The links are the ones the reachable nodes report, taken as undirected.
+//A bridge is a link, and an articulation point a node, whose loss would split its component in two.
+//The islands are told by their reachable nodes: an unreachable node links the nodes reporting it as a peer,
+//but one that no node reports is not an island of its own. The analysis is made once per version of the model,
+//as the views draw it on every page
+
+//A link between two nodes, A < B
+type Link struct {
+	A NodeID
+	B NodeID
+}
+
+type GraphReport struct {
+	Components         [][]NodeID //the largest first, the reachable nodes of each in the NodeID order
+	Bridges            []Link
+	ArticulationPoints []NodeID
+}
+
+//The network is split into islands which do not see each other
+func (gr *GraphReport) IsSplit() bool {
+	return len(gr.Components) > 1
+}
+
+func (gr *GraphReport) IsBridge(a, b NodeID) bool {
+	if b < a {
+		a, b = b, a
+	}
+	for _, l := range gr.Bridges {
+		if l.A == a && l.B == b {
+			return true
+		}
+	}
+	return false
+}
+
+func (gr *GraphReport) IsArticulationPoint(id NodeID) bool {
+	return containsNodeID(gr.ArticulationPoints, id)
+}
+
+//The AnalyzeGraph of a version of the model, made on the first call
+type graphCache struct {
+	once   sync.Once
+	report *GraphReport
+}
+
+func (bcn *BlockchainNet) AnalyzeGraph() *GraphReport {
+	if bcn.graph == nil {
+		return bcn.analyzeGraph()
+	}
+	bcn.graph.once.Do(func() { bcn.graph.report = bcn.analyzeGraph() })
+	return bcn.graph.report
+}
+
+func (bcn *BlockchainNet) analyzeGraph() *GraphReport {
+	adj := bcn.adjacency()
+	ids := make([]NodeID, 0, len(adj))
+	for id := range adj {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	gr := &GraphReport{}
+	t := &tarjan{adj: adj, order: map[NodeID]int{}, low: map[NodeID]int{}, report: gr}
+	for _, id := range ids {
+		if _, seen := t.order[id]; seen {
+			continue
+		}
+		t.component = nil
+		t.visit(id, "")
+		var island []NodeID
+		for _, m := range t.component {
+			if bcn.Nodes[m].IsReachable() {
+				island = append(island, m)
+			}
+		}
+		if len(island) == 0 {
+			continue
+		}
+		sort.Slice(island, func(i, j int) bool { return island[i] < island[j] })
+		gr.Components = append(gr.Components, island)
+	}
+	sort.SliceStable(gr.Components, func(i, j int) bool { return len(gr.Components[i]) > len(gr.Components[j]) })
+	sort.Slice(gr.Bridges, func(i, j int) bool {
+		return gr.Bridges[i].A < gr.Bridges[j].A || (gr.Bridges[i].A == gr.Bridges[j].A && gr.Bridges[i].B < gr.Bridges[j].B)
+	})
+	sort.Slice(gr.ArticulationPoints, func(i, j int) bool { return gr.ArticulationPoints[i] < gr.ArticulationPoints[j] })
+	return gr
+}
+
+//Every node of the model, with the sorted, de-duplicated neighbours the reachable nodes report
+func (bcn *BlockchainNet) adjacency() map[NodeID][]NodeID {
+	links := map[NodeID]map[NodeID]bool{}
+	for id := range bcn.Nodes {
+		links[id] = map[NodeID]bool{}
+	}
+	for _, n := range bcn.Nodes {
+		if !n.IsReachable() {
+			continue
+		}
+		for pid := range n.Peers {
+			if _, known := bcn.Nodes[pid]; !known || pid == n.ID {
+				continue
+			}
+			links[n.ID][pid] = true
+			links[pid][n.ID] = true
+		}
+	}
+	adj := make(map[NodeID][]NodeID, len(links))
+	for id, ps := range links {
+		adj[id] = make([]NodeID, 0, len(ps))
+		for p := range ps {
+			adj[id] = append(adj[id], p)
+		}
+		sort.Slice(adj[id], func(i, j int) bool { return adj[id][i] < adj[id][j] })
+	}
+	return adj
+}
+
+//The state of Tarjan's depth-first search for bridges and articulation points
+type tarjan struct {
+	adj       map[NodeID][]NodeID
+	order     map[NodeID]int //the visiting order
+	low       map[NodeID]int //the earliest node reachable from the subtree with one back link
+	counter   int
+	component []NodeID
+	report    *GraphReport
+}
+
+func (t *tarjan) visit(id NodeID, parent NodeID) {
+	t.order[id] = t.counter
+	t.low[id] = t.counter
+	t.counter++
+	t.component = append(t.component, id)
+	children := 0
+	cut := false
+	for _, p := range t.adj[id] {
+		if p == parent {
+			continue
+		}
+		if o, seen := t.order[p]; seen {
+			if o < t.low[id] {
+				t.low[id] = o
+			}
+			continue
+		}
+		children++
+		t.visit(p, id)
+		if t.low[p] < t.low[id] {
+			t.low[id] = t.low[p]
+		}
+		if t.low[p] > t.order[id] {
+			l := Link{id, p}
+			if p < id {
+				l = Link{p, id}
+			}
+			t.report.Bridges = append(t.report.Bridges, l)
+		}
+		if len(parent) > 0 && t.low[p] >= t.order[id] {
+			cut = true
+		}
+	}
+	//The root of the search is a cut node if it has more than one subtree
+	if cut || (len(parent) == 0 && children > 1) {
+		t.report.ArticulationPoints = append(t.report.ArticulationPoints, id)
+	}
+}
@@ -0,0 +1,140 @@
+package client
+
+import (
+	"encoding/json"
+	"github.com/san-lab/toolsmith/store"
+	"log"
+	"sort"
+	"time"
+)
+
+//The samples and the peer changes written to the store, and read back into the series on a restart.
+//The node IDs come from the enode keys, so they still match after the restart
+
+const samplesStream = "samples"
+const peersStream = "peers"
+
+type sampleRecord struct {
+	Node   NodeID  `json:"n"`
+	Metric string  `json:"m"`
+	Value  float64 `json:"v"`
+}
+
+//A peer link coming up or going down, as reported by the Node
+type PeerChange struct {
+	Time MyTime `json:"-"`
+	Node NodeID
+	Peer NodeID
+	Up   bool
+}
+
+//Attaches the store and reads the series and the last known peers back from it
+func (rpcClient *Client) SetStore(st *store.Store) {
+	rpcClient.mx.Lock()
+	rpcClient.store = st
+	rpcClient.mx.Unlock()
+	if st == nil {
+		return
+	}
+	ss := &rpcClient.series
+	ss.mx.Lock()
+	defer ss.mx.Unlock()
+	since := time.Now().Add(-st.GetConfig().Retention)
+	if st.GetConfig().Retention == 0 {
+		since = time.Time{}
+	}
+	err := st.Read(samplesStream, since, func(t time.Time, data json.RawMessage) {
+		var s sampleRecord
+		if json.Unmarshal(data, &s) == nil {
+			ss.add(s.Node, s.Metric, Point{MyTime(t), s.Value})
+		}
+	})
+	if err != nil {
+		log.Println(err)
+	}
+	ss.peers = map[NodeID]map[NodeID]bool{}
+	changes := rpcClient.PeerChanges()
+	for i := len(changes) - 1; i >= 0; i-- {
+		pc := changes[i]
+		if ss.peers[pc.Node] == nil {
+			ss.peers[pc.Node] = map[NodeID]bool{}
+		}
+		if pc.Up {
+			ss.peers[pc.Node][pc.Peer] = true
+		} else {
+			delete(ss.peers[pc.Node], pc.Peer)
+		}
+	}
+}
+
+func (rpcClient *Client) Store() *store.Store {
+	rpcClient.mx.RLock()
+	defer rpcClient.mx.RUnlock()
+	return rpcClient.store
+}
+
+//The peer changes kept in the store, the latest first
+func (rpcClient *Client) PeerChanges() []PeerChange {
+	st := rpcClient.Store()
+	if st == nil {
+		return nil
+	}
+	var changes []PeerChange
+	err := st.Read(peersStream, time.Time{}, func(t time.Time, data json.RawMessage) {
+		pc := PeerChange{Time: MyTime(t)}
+		if json.Unmarshal(data, &pc) == nil {
+			changes = append(changes, pc)
+		}
+	})
+	if err != nil {
+		log.Println(err)
+	}
+	sort.SliceStable(changes, func(i, j int) bool { return time.Time(changes[i].Time).After(time.Time(changes[j].Time)) })
+	return changes
+}
+
+//Writes a sample new to the series. The caller holds the series mx
+func (rpcClient *Client) storeSample(id NodeID, metric string, p Point) {
+	st := rpcClient.Store()
+	if st == nil {
+		return
+	}
+	if err := st.Append(samplesStream, time.Time(p.Time), sampleRecord{id, metric, p.Value}); err != nil {
+		log.Println(err)
+	}
+}
+
+//Writes the differences between the peers the node reports and the ones it reported before. The caller holds the series mx
+func (rpcClient *Client) storePeerChanges(n *Node) {
+	ss := &rpcClient.series
+	if ss.peers == nil {
+		ss.peers = map[NodeID]map[NodeID]bool{}
+	}
+	known := ss.peers[n.ID]
+	current := make(map[NodeID]bool, len(n.Peers))
+	for id := range n.Peers {
+		current[id] = true
+	}
+	ss.peers[n.ID] = current
+	st := rpcClient.Store()
+	if st == nil {
+		return
+	}
+	var changes []PeerChange
+	for id := range current {
+		if !known[id] {
+			changes = append(changes, PeerChange{Node: n.ID, Peer: id, Up: true})
+		}
+	}
+	for id := range known {
+		if !current[id] {
+			changes = append(changes, PeerChange{Node: n.ID, Peer: id, Up: false})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Peer < changes[j].Peer })
+	for _, pc := range changes {
+		if err := st.Append(peersStream, time.Time(n.LastReach), pc); err != nil {
+			log.Println(err)
+		}
+	}
+}
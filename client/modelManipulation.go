@@ -4,20 +4,30 @@ import (
 	"errors"
 	"fmt"
 	"log"
-	"strings"
+	"sync/atomic"
 	"time"
 )
 
 func (rpcClient *Client) Rescan() error {
-	for _, node := range rpcClient.NetModel.Nodes {
-		err := rpcClient.collectNodeInfo(node, true)
-		if err != nil {
-			log.Println(err)
-		}
-	}
+	rpcClient.probeMx.Lock()
+	defer rpcClient.probeMx.Unlock()
+	rpcClient.rescan()
 	return nil
 }
 
+//Rescans a copy of the model and publishes it. The caller has to hold the probeMx
+func (rpcClient *Client) rescan() *BlockchainNet {
+	bcn := rpcClient.NetModel().clone()
+	nodes := bcn.sortedNodes()
+	rpcClient.progress.start(len(nodes))
+	defer rpcClient.progress.finish()
+	rpcClient.collectAll(nodes, true)
+	rpcClient.publish(bcn)
+	rpcClient.recordSamples(bcn)
+	rpcClient.trackCliqueBallots(bcn)
+	return bcn
+}
+
 func (rpcClient *Client) collectNodeInfo(node *Node, refetch bool) error {
 
 	err := rpcClient.establishNodeClientVersion(node, refetch)
@@ -25,13 +35,19 @@ func (rpcClient *Client) collectNodeInfo(node *Node, refetch bool) error {
 		return err
 	}
 
-	if node.IsGeth() {
-		return rpcClient.collectGethNodeInfo(node, refetch)
-	} else if node.IsParity() {
-		return rpcClient.collectParityNodeInfo(node)
-	} else {
-		return errors.New("unsupported Eth client")
+	c, err := node.collector()
+	if err != nil {
+		return err
 	}
+	err = c.CollectNodeInfo(rpcClient, node, refetch)
+	if err != nil {
+		return err
+	}
+	rpcClient.collectConsensusInfo(node)
+	if err = rpcClient.trackHead(node); err != nil {
+		rpcClient.log(err.Error())
+	}
+	return nil
 
 }
 
@@ -48,6 +64,7 @@ func (rpcClient *Client) establishNodeClientVersion(stub *Node, refetch bool) (e
 		data.Context.TargetRPCEndpoint = addr
 		err = rpcClient.actualRpcCall(data)
 		if err != nil {
+			stub.setReachable(false) //a node gone down has to show up as such on the rescan
 			return err
 		}
 		cvr, ok := data.ParsedResult.(*StringResult)
@@ -64,160 +81,97 @@ func (rpcClient *Client) establishNodeClientVersion(stub *Node, refetch bool) (e
 }
 
 func (rpcClient *Client) SetPeers(node *Node) (err error) {
-	var method string
-
-	if node.IsGeth() {
-		method = "admin_peers"
-	} else if node.IsParity() {
-		method = "parity_netPeers"
-	} else {
-		err = errors.New("unsupported Client version: " + node.ClientVersion)
+	data, err := rpcClient.newPeersCall(node)
+	if err != nil {
 		return
 	}
-	data := rpcClient.NewCallData(method)
-	data.Context.TargetRPCEndpoint = node.RPCAddress
 	err = rpcClient.actualRpcCall(data)
-	if err != nil || !data.Parsed {
+	return node.applyPeers(data, err)
+}
+
+func (rpcClient *Client) newPeersCall(node *Node) (data *CallData, err error) {
+	c, err := node.collector()
+	if err != nil {
 		return
 	}
-	if node.IsGeth() {
-		var ok bool
-		node.JSONPeers, ok = data.ParsedResult.(*PeerArray)
-		if !ok {
-			err = errors.New("Could not parse the result of JSONPeers of " + node.ShortName)
-			return
-		}
-	} else if node.IsParity() {
-		peersResp, ok := data.ParsedResult.(*ParityPeerInfo)
-		if !ok {
-			errors.New("Could not parse the result of JSONPeers of " + node.ShortName)
-			return
-		}
-		node.JSONPeers = &peersResp.Peers
+	data = rpcClient.NewCallData(c.PeersMethod())
+	data.Context.TargetRPCEndpoint = node.RPCAddress
+	return
+}
 
+//Sets the peers of the node from the result of the peers call. Passes the call error through
+func (node *Node) applyPeers(data *CallData, callErr error) (err error) {
+	if callErr != nil || !data.Parsed {
+		return callErr
+	}
+	c, err := node.collector()
+	if err != nil {
+		return
+	}
+	node.JSONPeers, err = c.ParsePeers(data)
+	if err != nil {
+		return
 	}
 
+	//Rebuilt rather than added to, so that the dropped peers are gone from the graph
+	peers := make(map[NodeID]*Node, len(*node.JSONPeers))
 	for _, pi := range *node.JSONPeers {
 		n := NodeFromPeerInfo_Geth(nil, &pi)
-		node.Peers[NodeID(pi.ID)] = n
+		peers[n.ID] = n
 	}
+	node.Peers = peers
 
 	return
 }
 
-func (rpcClient *Client) collectGethNodeInfo(node *Node, fromScratch bool) error {
-	data := rpcClient.NewCallData("admin_nodeInfo")
+//A call to the RPC endpoint of the node
+func (rpcClient *Client) newNodeCall(node *Node, method string, params ...interface{}) *CallData {
+	data := rpcClient.NewCallData(method)
 	data.Context.TargetRPCEndpoint = node.RPCAddress
-	if fromScratch {
-
-		err := rpcClient.actualRpcCall(data)
-		if err != nil {
-			return err
-		}
-		ni, ok := data.ParsedResult.(*NodeInfo)
-		if !ok {
-			log.Printf("expected %T got %T", ni, data.ParsedResult)
-			return errors.New("not ok parsing the root node info")
-		}
-		FillNodeFromNodeInfo_Geth(node, ni)
-		node.isFromPeer = false
+	if len(params) > 0 {
+		data.Command.Params = params
 	}
-	//Get the txpool status
-	data.Command.Method = "txpool_status"
-	data.ParsedResult = nil
-	err := rpcClient.actualRpcCall(data)
-	if err != nil || !data.Parsed {
-		return err
-	}
-	node.TxpoolStatus = data.ParsedResult.(*TxpoolStatusSample)
-
-	//Get the BlockNumber
-	err = node.sampleBlockNo(rpcClient)
-
-	//Get peers
-	err = rpcClient.SetPeers(node)
-
-	return err
-}
-
-func (rpcClient *Client) collectParityNodeInfo(stub *Node) error {
-	data := rpcClient.NewCallData("parity_nodeName")
-	data.Context.TargetRPCEndpoint = stub.PrefAddress()
-	err := rpcClient.actualRpcCall(data)
-	if err != nil {
-		return err
-	}
-	stub.ShortName = string(*data.ParsedResult.(*StringResult))
-	data.Command.Method = "parity_enode"
-	err = rpcClient.actualRpcCall(data)
-	if err != nil {
-		return err
-	}
-	stub.Enode = string(*data.ParsedResult.(*StringResult)) //TODO: parsing errors unhandled!
-	stub.ID = NodeID(strings.Split(strings.Split(stub.Enode, "//")[1], "@")[0])
-	stub.FullName = stub.ShortName + "/" + stub.Enode
-	stub.isFromPeer = false
-	//TODO: this is fitting Parity info into Geth structures - ugly
-	data.Command.Method = "parity_pendingTransactions"
-	err = rpcClient.actualRpcCall(data)
-	if err != nil {
-		return err
-	}
-	err = stub.sampleBlockNo(rpcClient)
-	if err != nil {
-		return err
-	}
-	txs, ok := data.ParsedResult.(*ParityPendingTxs)
-	if !ok {
-		return errors.New("could not cast to parity_pendingTransactions while getting node info")
-	}
-	stub.TxpoolStatus = &TxpoolStatusSample{Pending: HexString(txs.Len()), Queued: HexString(0)}
-	stub.TxpoolStatus.stamp()
-
-	//Get peers
-	err = rpcClient.SetPeers(stub)
-	return err
+	return data
 }
 
 func (rpcClient *Client) DiscoverNetwork() error {
-	rpcClient.UnreachableAddresses = map[string]MyTime{}
-	rpcClient.NetModel.Nodes = map[NodeID]*Node{}
-	err := rpcClient.GetNetworkBasics()
+	rpcClient.probeMx.Lock()
+	defer rpcClient.probeMx.Unlock()
+	rpcClient.mx.Lock()
+	rpcClient.unreachableAddresses = map[string]MyTime{}
+	rpcClient.mx.Unlock()
+	bcn := NewBlockchainNet()
+	defer rpcClient.publish(bcn)
+	rpcClient.progress.start(0)
+	defer rpcClient.progress.finish()
+	err := rpcClient.getNetworkBasics(bcn)
 	if err != nil {
 		return err
 	}
-	rpcClient.collectNodeInfoRecursively(rpcClient.NetModel.Nodes[rpcClient.NetModel.AccessNodeID])
+	rpcClient.progress.found(1)
+	rpcClient.progress.probed(false)
+	rpcClient.collectNodeInfoRecursively(bcn, bcn.Nodes[bcn.AccessNodeID])
 	return nil
 }
 
-//Collect  nodes Info recursing through peers
-//The effects are in the NetworkModel
-func (rpcClient *Client) collectNodeInfoRecursively(parent *Node) error {
-
-	for id, peer := range parent.Peers {
-		if rpcClient.NetModel.Nodes[id] == nil {
-			node := NewNode()
-			node.ID = peer.ID
-			//node.prefAddress = peer.prefAddress
-			node.KnownAddresses[peer.PrefAddress()] = true
-			rpcClient.NetModel.Nodes[node.ID] = node
-			rpcClient.collectNodeInfo(node, true)
-			rpcClient.collectNodeInfoRecursively(node)
-		}
-	}
+var threshold = int64(time.Second * 15)
 
-	return nil
+//The max time for a new block to be mined/approved before a node is considered stuck
+func GetThreshold() time.Duration {
+	return time.Duration(atomic.LoadInt64(&threshold))
 }
 
-var Threshold = time.Second * 15
-var previousSample map[NodeID]int64
-var previousSampleTime time.Time
+func SetThreshold(t time.Duration) {
+	atomic.StoreInt64(&threshold, int64(t))
+}
 
 //Returns block-progress flag and the number of unreachable nodes and non-progressing nodes
 //Returns -1 as the number of unreachables if not enough time since previous probe
 func (rpcClient *Client) HeartBeat() (progress bool, unreachables int, stucknodes int) {
-	rpcClient.Rescan()
-	for _, node := range rpcClient.NetModel.Nodes {
+	rpcClient.probeMx.Lock()
+	defer rpcClient.probeMx.Unlock()
+	bcn := rpcClient.rescan()
+	for _, node := range bcn.Nodes {
 		if !node.IsReachable() {
 			unreachables++
 			continue
@@ -232,8 +186,12 @@ func (rpcClient *Client) HeartBeat() (progress bool, unreachables int, stucknode
 }
 
 func (rpcClient *Client) Bloop() (blocks map[string]interface{}, err error) {
+	rpcClient.probeMx.Lock()
+	defer rpcClient.probeMx.Unlock()
+	bcn := rpcClient.NetModel().clone()
+	defer rpcClient.publish(bcn)
 	blocks = map[string]interface{}{}
-	for _, node := range rpcClient.NetModel.Nodes {
+	for _, node := range bcn.Nodes {
 		err := node.sampleBlockNo(rpcClient)
 		if err != nil {
 			blocks[node.ShortName] = "UNREACHABLE!!!"
@@ -244,12 +202,24 @@ func (rpcClient *Client) Bloop() (blocks map[string]interface{}, err error) {
 		blocks[node.ShortName] = *node.LastBlockNumberSample
 
 	}
+	rpcClient.recordSamples(bcn)
 	return
 }
 
 //Verifies that the default RPC gateway works, Gets the basic Network information
 //Gets basic info on the entry Node and Peers of the entry Node
 func (rpcClient *Client) GetNetworkBasics() error {
+	rpcClient.probeMx.Lock()
+	defer rpcClient.probeMx.Unlock()
+	bcn := rpcClient.NetModel().clone()
+	err := rpcClient.getNetworkBasics(bcn)
+	if err == nil {
+		rpcClient.publish(bcn)
+	}
+	return err
+}
+
+func (rpcClient *Client) getNetworkBasics(bcn *BlockchainNet) error {
 	//First find out the network ID, if not known
 
 	callData := rpcClient.NewCallData("net_version")
@@ -259,7 +229,7 @@ func (rpcClient *Client) GetNetworkBasics() error {
 		return err
 	}
 	sr := callData.ParsedResult.(*StringResult)
-	rpcClient.NetModel.NetworkID = string(*sr)
+	bcn.NetworkID = string(*sr)
 	stub := NewNode()
 	//TODO: DevP2P address here stub.KnownAddresses[rpcClient.DefaultRPCEndpoint] = true
 	//stub.prefAddress = rpcClient.DefaultRPCEndpoint
@@ -268,8 +238,8 @@ func (rpcClient *Client) GetNetworkBasics() error {
 	if err != nil {
 		return err
 	}
-	rpcClient.NetModel.AccessNodeID = stub.ID
-	rpcClient.NetModel.Nodes[stub.ID] = stub
+	bcn.AccessNodeID = stub.ID
+	bcn.Nodes[stub.ID] = stub
 
 	return nil
 }
@@ -292,11 +262,23 @@ func (rpcClient *Client) collectNodePeerInfo(node *Node, rebuild bool) (err erro
 }
 
 func (n *Node) sampleBlockNo(rpc *Client) error {
-	callData := rpc.NewCallData("eth_blockNumber")
-	callData.Context.TargetRPCEndpoint = n.RPCAddress
+	if head, ok := rpc.liveHead(n.ID); ok {
+		sample := *head
+		if n.LastBlockNumberSample != nil && head.BlockNumber <= n.LastBlockNumberSample.BlockNumber {
+			sample.stamp() //no new head since, so it is the time of asking that counts
+		}
+		return n.recordBlockNumber(&sample)
+	}
+	callData := rpc.newNodeCall(n, "eth_blockNumber")
 	err := rpc.actualRpcCall(callData)
-	if err != nil || !callData.Parsed {
-		return err
+	return n.applyBlockNumber(callData, err)
+}
+
+//Updates the block number samples and the progress flag from the result of eth_blockNumber.
+//Passes the call error through
+func (n *Node) applyBlockNumber(callData *CallData, callErr error) (err error) {
+	if callErr != nil || !callData.Parsed {
+		return callErr
 	}
 	blockNumberSample, ok := callData.ParsedResult.(*BlockNumberSample)
 	if !ok {
@@ -304,6 +286,10 @@ func (n *Node) sampleBlockNo(rpc *Client) error {
 		log.Println(err)
 		return err
 	}
+	return n.recordBlockNumber(blockNumberSample)
+}
+
+func (n *Node) recordBlockNumber(blockNumberSample *BlockNumberSample) error {
 	if n.LastBlockNumberSample == nil {
 		n.LastBlockNumberSample = blockNumberSample
 		return nil
@@ -313,7 +299,7 @@ func (n *Node) sampleBlockNo(rpc *Client) error {
 		n.LastBlockNumberSample = blockNumberSample
 		n.progress = true
 	} else {
-		if time.Time(blockNumberSample.Sampled).Sub(time.Time(n.LastBlockNumberSample.Sampled)) > Threshold {
+		if time.Time(blockNumberSample.Sampled).Sub(time.Time(n.LastBlockNumberSample.Sampled)) > GetThreshold() {
 			n.progress = false
 		}
 	}
@@ -0,0 +1,125 @@
+package client
+
+import (
+	"encoding/json"
+	"net"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	RegisterCollector(nethermindCollector{})
+}
+
+type nethermindCollector struct{}
+
+func (nethermindCollector) Name() string {
+	return "Nethermind"
+}
+
+func (nethermindCollector) Matches(clientVersion string) bool {
+	return strings.HasPrefix(clientVersion, "Nethermind")
+}
+
+func (nethermindCollector) CollectNodeInfo(rpcClient *Client, node *Node, fromScratch bool) error {
+	return rpcClient.collectAdminNodeInfo(nethermindCollector{}, node, fromScratch)
+}
+
+func (nethermindCollector) PeersMethod() string {
+	return "admin_peers"
+}
+
+//The admin_peers call is decoded into a PeerArray, so the Nethermind format is parsed from the raw result
+func (nethermindCollector) ParsePeers(data *CallData) (*PeerArray, error) {
+	peers := NethermindPeerArray{}
+	err := peers.parse(data)
+	if err != nil {
+		return nil, err
+	}
+	return peers.peerArray(), nil
+}
+
+func (nethermindCollector) TxpoolStatusMethod() string {
+	return "txpool_status"
+}
+
+func (nethermindCollector) ParseTxpoolStatus(data *CallData) (*TxpoolStatusSample, error) {
+	return parseGethTxpoolStatus(data)
+}
+
+func (nethermindCollector) TxpoolMethod() string {
+	return "txpool_inspect"
+}
+
+func (nethermindCollector) PendingTxsMethod() string {
+	return "txpool_content"
+}
+
+func (nethermindCollector) ParsePendingTxs(data *CallData) ([]*Transaction, []string, error) {
+	return parseGethPendingTxs(data)
+}
+
+func (nethermindCollector) Methods() [][]string {
+	return NethermindCommsSet
+}
+
+func (nethermindCollector) Results() map[string]func() interface{} {
+	return nil
+}
+
+func (n *Node) IsNethermind() bool {
+	return strings.HasPrefix(n.ClientVersion, "Nethermind")
+}
+
+//Nethermind's admin_peers has its own format
+type NethermindPeerInfo struct {
+	ClientID   string `json:"clientId"`
+	Host       string `json:"host"`
+	Port       int    `json:"port"`
+	Address    string `json:"address"`
+	IsBootnode bool   `json:"isBootnode"`
+	IsStatic   bool   `json:"isStatic"`
+	IsTrusted  bool   `json:"isTrusted"`
+	Enode      string `json:"enode"`
+	ClientType string `json:"clientType"`
+}
+
+type NethermindPeerArray []NethermindPeerInfo
+
+func (npa *NethermindPeerArray) parse(data *CallData) error {
+	return json.Unmarshal(data.Response.Result, npa)
+}
+
+//Fitting Nethermind info into Geth structures
+func (npa NethermindPeerArray) peerArray() *PeerArray {
+	pa := PeerArray{}
+	for _, np := range npa {
+		pi := PeerInfo{Name: np.ClientID, Enode: np.Enode}
+		pi.Network.RemoteAddress = np.Address
+		if len(np.Host) > 0 {
+			pi.Network.RemoteAddress = net.JoinHostPort(np.Host, strconv.Itoa(np.Port))
+		}
+		pa = append(pa, pi)
+	}
+	return &pa
+}
+
+var NethermindCommsSet = [][]string{GenericRpcEthComms, GenericRpcWeb3Comms, GenericRpcNetComms, GethRpcTxpoolComms, NethermindRpcAdminComms,
+	NethermindRpcCliqueComms, NethermindRpcDebugComms, NethermindRpcParityComms, NethermindRpcOtherComms, RpcTraceComms}
+
+var NethermindRpcAdminComms = []string{"admin_addPeer", "admin_dataDir", "admin_nodeInfo", "admin_peers", "admin_removePeer", "admin_setSolc"}
+
+var NethermindRpcCliqueComms = []string{"clique_discard", "clique_getBlockSigner", "clique_getSigners", "clique_getSignersAnnotated",
+	"clique_getSignersAtHash", "clique_getSignersAtHashAnnotated", "clique_getSignersAtNumber", "clique_getSnapshot", "clique_getSnapshotAtHash",
+	"clique_produceBlock", "clique_propose"}
+
+var NethermindRpcDebugComms = []string{"debug_deleteChainSlice", "debug_getBlockRlp", "debug_getBlockRlpByHash", "debug_getChainLevel",
+	"debug_getConfigValue", "debug_getSyncStage", "debug_insertReceipts", "debug_migrateReceipts", "debug_resetHead", "debug_traceBlock",
+	"debug_traceBlockByHash", "debug_traceBlockByNumber", "debug_traceTransaction", "debug_traceTransactionByBlockAndIndex",
+	"debug_traceTransactionByBlockhashAndIndex"}
+
+var NethermindRpcParityComms = []string{"parity_clearEngineSigner", "parity_enode", "parity_getBlockReceipts", "parity_netPeers",
+	"parity_pendingTransactions", "parity_setEngineSigner", "parity_setEngineSignerSecret"}
+
+var NethermindRpcOtherComms = []string{"eth_chainId", "eth_feeHistory", "eth_getProof", "eth_maxPriorityFeePerGas", "eth_pendingTransactions",
+	"health_nodeStatus", "net_localAddress", "net_localEnode", "proof_call", "proof_getTransactionByHash", "proof_getTransactionReceipt"}
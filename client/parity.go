@@ -0,0 +1,193 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+func init() {
+	RegisterCollector(parityCollector{})
+}
+
+type parityCollector struct{}
+
+func (parityCollector) Name() string {
+	return "Parity"
+}
+
+func (parityCollector) Matches(clientVersion string) bool {
+	return strings.HasPrefix(clientVersion, "Parity")
+}
+
+//Parity has no admin api, the node info is put together from the parity_ calls
+func (parityCollector) CollectNodeInfo(rpcClient *Client, stub *Node, fromScratch bool) error {
+	nodeName := rpcClient.newNodeCall(stub, "parity_nodeName")
+	enode := rpcClient.newNodeCall(stub, "parity_enode")
+	pending := rpcClient.newNodeCall(stub, parityCollector{}.TxpoolStatusMethod())
+	blockNo := rpcClient.newNodeCall(stub, "eth_blockNumber")
+	peers := rpcClient.newNodeCall(stub, "parity_netPeers")
+	errs := rpcClient.callAll(nodeName, enode, pending, blockNo, peers)
+	for _, err := range errs[:2] {
+		if err != nil {
+			return err
+		}
+	}
+	name, ok := nodeName.ParsedResult.(*StringResult)
+	if !ok {
+		return errors.New("could not parse parity_nodeName")
+	}
+	stub.ShortName = string(*name)
+	en, ok := enode.ParsedResult.(*StringResult)
+	if !ok || !strings.Contains(string(*en), "//") {
+		return errors.New("could not parse parity_enode")
+	}
+	stub.Enode = string(*en)
+	stub.ID = nodeID("", stub.Enode)
+	stub.FullName = stub.ShortName + "/" + stub.Enode
+	stub.isFromPeer = false
+	//TODO: this is fitting Parity info into Geth structures - ugly
+	if errs[2] != nil {
+		return errs[2]
+	}
+	err := stub.applyBlockNumber(blockNo, errs[3])
+	if err != nil {
+		return err
+	}
+	stub.TxpoolStatus, err = parityCollector{}.ParseTxpoolStatus(pending)
+	if err != nil {
+		return err
+	}
+
+	//Get peers
+	err = stub.applyPeers(peers, errs[4])
+	return err
+}
+
+func (parityCollector) PeersMethod() string {
+	return "parity_netPeers"
+}
+
+func (parityCollector) ParsePeers(data *CallData) (*PeerArray, error) {
+	peersResp, ok := data.ParsedResult.(*ParityPeerInfo)
+	if !ok {
+		return nil, errors.New("could not parse the result of parity_netPeers")
+	}
+	return &peersResp.Peers, nil
+}
+
+//Parity has no txpool api, the pending transactions are counted
+func (parityCollector) TxpoolStatusMethod() string {
+	return "parity_pendingTransactions"
+}
+
+func (parityCollector) ParseTxpoolStatus(data *CallData) (*TxpoolStatusSample, error) {
+	txs, ok := data.ParsedResult.(*ParityPendingTxs)
+	if !ok {
+		return nil, errors.New("could not cast to parity_pendingTransactions while getting node info")
+	}
+	status := &TxpoolStatusSample{Pending: HexString(txs.Len()), Queued: HexString(0)}
+	status.stamp()
+	return status, nil
+}
+
+func (parityCollector) TxpoolMethod() string {
+	return "parity_pendingTransactions"
+}
+
+func (parityCollector) PendingTxsMethod() string {
+	return "parity_pendingTransactions"
+}
+
+//Parity lists only the pending transactions
+func (parityCollector) ParsePendingTxs(data *CallData) ([]*Transaction, []string, error) {
+	txs, ok := data.ParsedResult.(*ParityPendingTxs)
+	if !ok {
+		return nil, nil, errors.New("could not parse the result of parity_pendingTransactions")
+	}
+	pending := txs.transactions()
+	var hashes []string
+	for _, tx := range pending {
+		hashes = append(hashes, tx.Hash)
+	}
+	return pending, hashes, nil
+}
+
+func (parityCollector) Methods() [][]string {
+	return ParityCommsSet
+}
+
+func (parityCollector) Results() map[string]func() interface{} {
+	return map[string]func() interface{}{
+		"parity_enode":               func() interface{} { s := StringResult(""); return &s },
+		"parity_nodeName":            func() interface{} { s := StringResult(""); return &s },
+		"parity_pendingTransactions": func() interface{} { return &ParityPendingTxs{} },
+		"parity_netPeers":            func() interface{} { return &ParityPeerInfo{} },
+	}
+}
+
+func (n *Node) IsParity() bool {
+	return strings.HasPrefix(n.ClientVersion, "Parity")
+}
+
+type ParityPeerInfo struct {
+	Active    int `json:"active"`
+	Connected int `json:"connected"`
+	Max       int `json:"max"`
+	Peers     PeerArray
+}
+
+type ParityPendingTxs []interface{}
+
+func (ppt ParityPendingTxs) Len() int {
+	return len([]interface{}(ppt))
+}
+
+//The pending transactions fitted into the Transaction, those which do not fit are left out
+func (ppt ParityPendingTxs) transactions() []*Transaction {
+	var txs []*Transaction
+	for _, raw := range ppt {
+		tx := &Transaction{}
+		bytes, err := json.Marshal(raw)
+		if err == nil && json.Unmarshal(bytes, tx) == nil && len(tx.Hash) > 0 {
+			txs = append(txs, tx)
+		}
+	}
+	return txs
+}
+
+var ParityCommsSet = [][]string{GenericRpcWeb3Comms, GenericRpcNetComms, GenericRpcEthComms, RpcPersonalComms, RpcParityComms, RpcParityAccountsComms,
+	RpcParitySetComms, RpcParityPubsubComms, RpcParitySignerComms, RpcParityTraceComms, RpcParityShhComms, RpcParitySecretstoreComms}
+
+var RpcParityComms = []string{"parity_cidV0", "parity_composeTransaction", "parity_consensusCapability", "parity_decryptMessage",
+	"parity_encryptMessage", "parity_futureTransactions", "parity_allTransactions", "parity_getBlockHeaderByNumber", "parity_listOpenedVaults",
+	"parity_listStorageKeys", "parity_listVaults", "parity_localTransactions", "parity_releasesInfo", "parity_signMessage", "parity_versionInfo",
+	"parity_changeVault", "parity_changeVaultPassword", "parity_closeVault", "parity_getVaultMeta", "parity_newVault", "parity_openVault",
+	"parity_setVaultMeta", "parity_accountsInfo", "parity_checkRequest", "parity_defaultAccount", "parity_generateSecretPhrase",
+	"parity_hardwareAccountsInfo", "parity_listAccounts", "parity_phraseToAddress", "parity_postSign", "parity_postTransaction",
+	"parity_defaultExtraData", "parity_extraData", "parity_gasCeilTarget", "parity_gasFloorTarget", "parity_minGasPrice", "parity_transactionsLimit",
+	"parity_devLogs", "parity_devLogsLevels", "parity_chain", "parity_chainId", "parity_chainStatus", "parity_gasPriceHistogram",
+	"parity_netChain", "parity_netPeers", "parity_netPort", "parity_nextNonce", "parity_pendingTransactions", "parity_pendingTransactionsStats",
+	"parity_registryAddress", "parity_removeTransaction", "parity_rpcSettings", "parity_unsignedTransactionsCount",
+	"parity_dappsUrl", "parity_enode", "parity_mode", "parity_nodeKind", "parity_nodeName", "parity_wsUrl"}
+
+var RpcParityAccountsComms = []string{"parity_allAccountsInfo", "parity_changePassword", "parity_deriveAddressHash", "parity_deriveAddressIndex",
+	"parity_exportAccount", "parity_getDappAddresses", "parity_getDappDefaultAddress", "parity_getNewDappsAddresses", "parity_getNewDappsDefaultAddress",
+	"parity_importGethAccounts", "parity_killAccount", "parity_listGethAccounts", "parity_listRecentDapps", "parity_newAccountFromPhrase",
+	"parity_newAccountFromSecret", "parity_newAccountFromWallet", "parity_removeAddress", "parity_setAccountMeta", "parity_setAccountName",
+	"parity_setDappAddresses", "parity_setDappDefaultAddress", "parity_setNewDappsAddresses", "parity_setNewDappsDefaultAddress",
+	"parity_testPassword"}
+
+var RpcParitySetComms = []string{"parity_acceptNonReservedPeers", "parity_addReservedPeer", "parity_dappsList", "parity_dropNonReservedPeers",
+	"parity_executeUpgrade", "parity_hashContent", "parity_removeReservedPeer", "parity_setAuthor", "parity_setChain", "parity_setEngineSigner",
+	"parity_setExtraData", "parity_setGasCeilTarget", "parity_setGasFloorTarget", "parity_setMaxTransactionGas", "parity_setMinGasPrice",
+	"parity_setMode", "parity_setTransactionsLimit", "parity_upgradeReady"}
+
+var RpcParityPubsubComms = []string{"parity_subscribe", "parity_unsubscribe"}
+
+var RpcParitySignerComms = []string{"signer_confirmRequest", "signer_confirmRequestRaw", "signer_confirmRequestWithToken", "signer_generateAuthorizationToken",
+	"signer_generateWebProxyAccessToken", "signer_rejectRequest", "signer_requestsToConfirm", "signer_subscribePending", "signer_unsubscribePending"}
+
+var RpcParityTraceComms = []string{}       //TODO
+var RpcParityShhComms = []string{}         //TODO
+var RpcParitySecretstoreComms = []string{} //TODO
@@ -0,0 +1,185 @@
+package client
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+//Comparing the pools of the nodes, to tell the transactions which do not propagate. A transaction submitted
+//to a node which does not mine has to reach a miner (a clique signer, a BFT validator, or a node reporting eth_mining)
+//to be included, so those seen only on the other nodes for longer than the threshold are flagged.
+//The pending transactions are compared, as listed by the node's collector (txpool_content of Geth and the likes,
+//parity_pendingTransactions of Parity, txpool_besuTransactions of Besu): the queued ones are not passed on to the peers anyway
+
+const DefaultPropagationThreshold = time.Minute
+
+//A pending transaction and the pools it is in
+type PoolPresence struct {
+	Hash      string
+	From      string
+	Nonce     HexString
+	On        map[NodeID]bool
+	FirstSeen MyTime //by the first node whose pool had it
+	OnMiner   bool
+	Flagged   bool //only on the nodes which do not mine, for longer than the threshold
+}
+
+func (pp *PoolPresence) Age() time.Duration {
+	return time.Since(time.Time(pp.FirstSeen)).Round(time.Second)
+}
+
+func (pp *PoolPresence) IsOn(id NodeID) bool {
+	return pp.On[id]
+}
+
+type PropagationReport struct {
+	Sampled   MyTime
+	Threshold time.Duration
+	Nodes     []*Node           //the nodes whose pools were read
+	Miners    map[NodeID]bool   //of the Nodes
+	Unread    map[NodeID]string //the reachable nodes whose pool could not be read, with the reason
+	Txs       []*PoolPresence   //the transactions missing from at least one of the Nodes, the flagged first
+	Missing   map[NodeID]int    //how many of the Txs each node lacks
+	Complete  int               //the transactions in the pools of all the Nodes
+	Flagged   int
+}
+
+//Without a miner among the compared nodes nothing can be flagged
+func (pr *PropagationReport) HasMiner() bool {
+	return len(pr.Miners) > 0
+}
+
+//The pending transactions of a node's pool
+type nodePool struct {
+	node    *Node
+	pending []*Transaction
+	hashes  []string //of all the transactions in the pool, the queued as well
+	mining  bool
+	err     error
+}
+
+//Reads the pools of the reachable nodes over the worker pool and compares them
+func (rpcClient *Client) ComparePools(threshold time.Duration) *PropagationReport {
+	rep := &PropagationReport{Sampled: MyTime(time.Now()), Threshold: threshold, Miners: map[NodeID]bool{},
+		Unread: map[NodeID]string{}, Missing: map[NodeID]int{}}
+	var nodes []*Node
+	for _, n := range rpcClient.NetModel().sortedNodes() {
+		if n.IsReachable() {
+			nodes = append(nodes, n)
+		}
+	}
+	pools := make([]*nodePool, len(nodes))
+	jobs := make(chan int)
+	wg := sync.WaitGroup{}
+	for i := 0; i < rpcClient.workerCount(len(nodes)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				pools[j] = rpcClient.readPool(nodes[j])
+			}
+		}()
+	}
+	for j := range nodes {
+		jobs <- j
+	}
+	close(jobs)
+	wg.Wait()
+
+	byHash := map[string]*PoolPresence{}
+	var all []*PoolPresence
+	for _, pool := range pools {
+		if pool.err != nil {
+			rep.Unread[pool.node.ID] = pool.err.Error()
+			continue
+		}
+		rep.Nodes = append(rep.Nodes, pool.node)
+		if pool.mining {
+			rep.Miners[pool.node.ID] = true
+		}
+		seen := rpcClient.markPoolSeen(pool.node.ID, pool.hashes)
+		for _, tx := range pool.pending {
+			pp, ok := byHash[tx.Hash]
+			if !ok {
+				pp = &PoolPresence{Hash: tx.Hash, From: tx.From, Nonce: tx.Nonce, On: map[NodeID]bool{}, FirstSeen: seen[tx.Hash]}
+				byHash[tx.Hash] = pp
+				all = append(all, pp)
+			}
+			//Besu tells only the hashes
+			if len(pp.From) == 0 {
+				pp.From, pp.Nonce = tx.From, tx.Nonce
+			}
+			pp.On[pool.node.ID] = true
+			pp.OnMiner = pp.OnMiner || pool.mining
+			if time.Time(seen[tx.Hash]).Before(time.Time(pp.FirstSeen)) {
+				pp.FirstSeen = seen[tx.Hash]
+			}
+		}
+	}
+	for _, pp := range all {
+		if len(pp.On) == len(rep.Nodes) {
+			rep.Complete++
+			continue
+		}
+		for _, n := range rep.Nodes {
+			if !pp.On[n.ID] {
+				rep.Missing[n.ID]++
+			}
+		}
+		pp.Flagged = rep.HasMiner() && !pp.OnMiner && pp.Age() > threshold
+		if pp.Flagged {
+			rep.Flagged++
+		}
+		rep.Txs = append(rep.Txs, pp)
+	}
+	//The flagged first, then the ones missing from the most nodes, the oldest first
+	sort.Slice(rep.Txs, func(i, j int) bool {
+		a, b := rep.Txs[i], rep.Txs[j]
+		if a.Flagged != b.Flagged {
+			return a.Flagged
+		}
+		if len(a.On) != len(b.On) {
+			return len(a.On) < len(b.On)
+		}
+		return time.Time(a.FirstSeen).Before(time.Time(b.FirstSeen))
+	})
+	return rep
+}
+
+//The pending transactions of the node, and whether it mines, in one round trip if the node takes batches
+func (rpcClient *Client) readPool(node *Node) *nodePool {
+	pool := &nodePool{node: node}
+	c, err := node.collector()
+	if err != nil {
+		pool.err = err
+		return pool
+	}
+	method := c.PendingTxsMethod()
+	content := rpcClient.newNodeCall(node, method)
+	mining := rpcClient.newNodeCall(node, "eth_mining")
+	errs := rpcClient.callAll(content, mining)
+	pool.err = errs[0]
+	if pool.err == nil && content.Response.Error != nil {
+		pool.err = *content.Response.Error
+	}
+	if pool.err == nil && !content.Parsed {
+		pool.err = errors.New("could not parse the result of " + method)
+	}
+	if pool.err != nil {
+		return pool
+	}
+	if pool.pending, pool.hashes, pool.err = c.ParsePendingTxs(content); pool.err != nil {
+		return pool
+	}
+	switch {
+	case node.Clique != nil:
+		pool.mining = node.Clique.IsSealing()
+	case node.BFT != nil:
+		pool.mining = node.BFT.IsValidator()
+	case errs[1] == nil && mining.Parsed:
+		pool.mining = *mining.ParsedResult.(*bool)
+	}
+	return pool
+}
@@ -0,0 +1,175 @@
+package client
+
+import (
+	"errors"
+	"log"
+	"sort"
+	"time"
+)
+
+//Reorg detection. Every node remembers the hashes of the last blocks of its chain. The head is asked for on each probe;
+//if it does not extend the remembered chain, the new chain is followed back to the block both share,
+//and the remembered blocks above it have been reorganized away
+
+const headHistoryLength = 64  //blocks remembered per node
+const reorgHistoryLength = 32 //reorgs remembered per node
+
+type BlockRef struct {
+	Number     HexString
+	Hash       string
+	ParentHash string
+}
+
+type Reorg struct {
+	Depth    int //blocks of the old chain replaced
+	OldHead  BlockRef
+	NewHead  BlockRef
+	Ancestor HexString //the last block common to both chains, -1 if older than the remembered blocks (the Depth is then the least it can be)
+	Detected MyTime
+}
+
+//A reorg and the node it happened on
+type NodeReorg struct {
+	Node NodeID
+	Reorg
+}
+
+//The reorgs of all the nodes, the latest first
+func (bcn *BlockchainNet) Reorgs() []NodeReorg {
+	var all []NodeReorg
+	for _, n := range bcn.Nodes {
+		for _, r := range n.Reorgs {
+			all = append(all, NodeReorg{n.ID, r})
+		}
+	}
+	sort.Slice(all, func(i, j int) bool {
+		return time.Time(all[i].Detected).After(time.Time(all[j].Detected))
+	})
+	return all
+}
+
+//Asks the node for its head and compares it with the remembered chain, unless the block number the probe has just read
+//is that of the remembered head: a reorg at the same height shows once the chain grows, as the next block does not link to it.
+//The history and the reorgs are replaced rather than modified, as the old ones may be shared with a published model
+func (rpcClient *Client) trackHead(node *Node) error {
+	if known := node.heads; len(known) > 0 && known[len(known)-1].Number == node.blockNumber() {
+		return nil
+	}
+	heads, err := rpcClient.blockRefs(node, "latest")
+	if err != nil {
+		return err
+	}
+	head := heads[0]
+	known := node.heads
+	if len(known) == 0 || head.Number-known[len(known)-1].Number > headHistoryLength {
+		node.heads = []BlockRef{head} //nothing to compare with
+		return nil
+	}
+	newest := known[len(known)-1]
+	if head.Hash == newest.Hash {
+		return nil
+	}
+	//The new chain, from the head down to the block linking it to the remembered one
+	segment := []BlockRef{head}
+	for {
+		low := segment[len(segment)-1]
+		if low.Number <= known[0].Number {
+			break
+		}
+		if h, ok := hashAt(known, low.Number-1); ok && h == low.ParentHash {
+			break
+		}
+		stop := low.Number - 4 //down the remembered blocks a few at a time
+		if low.Number-1 > newest.Number {
+			stop = newest.Number + 1 //the blocks since the last probe
+		}
+		var numbers []interface{}
+		for n := low.Number - 1; n >= stop && n >= known[0].Number; n-- {
+			numbers = append(numbers, n.hex())
+		}
+		more, err := rpcClient.blockRefs(node, numbers...)
+		if err != nil {
+			return err
+		}
+		segment = append(segment, more...)
+	}
+	//The highest block of the segment already remembered, if the head has gone back along the same chain
+	//or more was fetched than needed. Otherwise the one the segment links to
+	ancestor := HexString(-1)
+	for _, b := range segment {
+		if h, ok := hashAt(known, b.Number); ok && h == b.Hash {
+			ancestor = b.Number
+			break
+		}
+	}
+	low := segment[len(segment)-1]
+	if h, ok := hashAt(known, low.Number-1); ancestor < 0 && ok && h == low.ParentHash {
+		ancestor = low.Number - 1
+	}
+	var history []BlockRef
+	for _, b := range known {
+		if b.Number <= ancestor {
+			history = append(history, b)
+		}
+	}
+	for i := len(segment) - 1; i >= 0; i-- {
+		if segment[i].Number > ancestor {
+			history = append(history, segment[i])
+		}
+	}
+	if len(history) > headHistoryLength {
+		history = history[len(history)-headHistoryLength:]
+	}
+	node.heads = history
+
+	depth := int(newest.Number - ancestor)
+	if ancestor < 0 {
+		depth = int(newest.Number - known[0].Number + 1)
+	}
+	if depth <= 0 {
+		return nil
+	}
+	reorg := Reorg{Depth: depth, OldHead: newest, NewHead: head, Ancestor: ancestor, Detected: MyTime(time.Now())}
+	log.Printf("Reorg of %v blocks on %s, %v %s -> %v %s\n", depth, node.ShortName, newest.Number, newest.Hash, head.Number, head.Hash)
+	reorgs := append([]Reorg{}, node.Reorgs...)
+	reorgs = append(reorgs, reorg)
+	if len(reorgs) > reorgHistoryLength {
+		reorgs = reorgs[len(reorgs)-reorgHistoryLength:]
+	}
+	node.Reorgs = reorgs
+	return nil
+}
+
+//The blocks at the given numbers (or "latest"), in one round trip if the node takes batches
+func (rpcClient *Client) blockRefs(node *Node, numbers ...interface{}) ([]BlockRef, error) {
+	calls := make([]*CallData, len(numbers))
+	for i, n := range numbers {
+		calls[i] = rpcClient.newNodeCall(node, "eth_getBlockByNumber", n, false)
+	}
+	refs := make([]BlockRef, len(numbers))
+	for i, err := range rpcClient.callAll(calls...) {
+		if err == nil && calls[i].Response.Error != nil {
+			err = *calls[i].Response.Error
+		}
+		if err == nil && (!calls[i].Parsed || len(calls[i].ParsedResult.(*Block).Hash) == 0) {
+			err = errors.New("no block " + node.ShortName)
+		}
+		if err != nil {
+			return nil, err
+		}
+		h := calls[i].ParsedResult.(*Block)
+		refs[i] = BlockRef{Number: h.Number, Hash: h.Hash, ParentHash: h.ParentHash}
+	}
+	return refs, nil
+}
+
+func hashAt(blocks []BlockRef, number HexString) (string, bool) {
+	if len(blocks) == 0 || number < blocks[0].Number {
+		return "", false
+	}
+	i := int(number - blocks[0].Number)
+	if i >= len(blocks) {
+		return "", false
+	}
+	return blocks[i].Hash, true
+}
@@ -0,0 +1,232 @@
+package client
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+	"time"
+)
+
+//Replacing a pending transaction which blocks the later nonces of its sender. The pools take a transaction
+//of the same sender and nonce in place of the one they hold if it pays enough more: by 10% (the txpool.pricebump
+//of Geth, and the default of Besu and Nethermind) of the gas price, or of both the fee cap and the tip of
+//a dynamic fee transaction. The replacement either cancels the original, sending nothing to the sender itself,
+//or resubmits it at the higher price
+
+const replacementBump = 10 //percent
+const transferGas = 21000
+
+type Replacement struct {
+	Original             *Transaction
+	Cancel               bool
+	To                   string
+	Value                *BigHex
+	Input                string
+	Gas                  HexString
+	GasPrice             *BigHex //of a legacy transaction
+	MaxFeePerGas         *BigHex //of a dynamic fee one
+	MaxPriorityFeePerGas *BigHex
+}
+
+//The lowest price the pools take in place of the price paid
+func bumped(paid *BigHex) *BigHex {
+	if paid == nil {
+		return nil
+	}
+	b := &BigHex{}
+	b.Mul(&paid.Int, big.NewInt(100+replacementBump))
+	b.Quo(&b.Int, big.NewInt(100))
+	if b.Cmp(&paid.Int) <= 0 {
+		b.Add(&paid.Int, big.NewInt(1))
+	}
+	return b
+}
+
+func maxBigHex(a, b *BigHex) *BigHex {
+	if b != nil && (a == nil || b.Cmp(&a.Int) > 0) {
+		return b
+	}
+	return a
+}
+
+func (r *Replacement) IsDynamicFee() bool {
+	return r.Original.MaxFeePerGas != nil && r.Original.MaxPriorityFeePerGas != nil
+}
+
+//The lowest fees meeting the replacement rule
+func (r *Replacement) MinGasPrice() *BigHex {
+	return bumped(r.Original.GasPrice)
+}
+
+func (r *Replacement) MinMaxFeePerGas() *BigHex {
+	return bumped(r.Original.MaxFeePerGas)
+}
+
+func (r *Replacement) MinMaxPriorityFeePerGas() *BigHex {
+	return bumped(r.Original.MaxPriorityFeePerGas)
+}
+
+//Proposes the replacement of the pending transaction, with the fees bumped enough for the pools to take it
+//and at least what the chain asks for now: the gas price the node suggests, or twice the base fee over the tip
+func (rpcClient *Client) ProposeReplacement(node *Node, hash string, cancel bool) (*Replacement, error) {
+	tx, _, err := rpcClient.GetTransaction(node, hash)
+	if err != nil {
+		return nil, err
+	}
+	if !tx.IsPending() {
+		return nil, errors.New("the transaction is already mined in the block " + tx.BlockNumber.hex())
+	}
+	r := &Replacement{Original: tx, Cancel: cancel, To: tx.To, Value: tx.Value, Input: tx.Input, Gas: tx.Gas}
+	if cancel {
+		r.To, r.Value, r.Input, r.Gas = tx.From, &BigHex{}, "0x", transferGas
+	}
+	head := rpcClient.newNodeCall(node, "eth_getBlockByNumber", "latest", false)
+	gasPrice := rpcClient.newNodeCall(node, "eth_gasPrice")
+	errs := rpcClient.callAll(head, gasPrice)
+	var baseFee, suggested *BigHex
+	if b, ok := head.ParsedResult.(*Block); ok && errs[0] == nil && head.Parsed {
+		baseFee = b.BaseFeePerGas
+	}
+	if gp, ok := gasPrice.ParsedResult.(*BigHex); ok && errs[1] == nil && gasPrice.Parsed {
+		suggested = gp
+	}
+	if !r.IsDynamicFee() {
+		r.GasPrice = maxBigHex(r.MinGasPrice(), suggested)
+		return r, nil
+	}
+	r.MaxPriorityFeePerGas = r.MinMaxPriorityFeePerGas()
+	r.MaxFeePerGas = r.MinMaxFeePerGas()
+	if baseFee != nil {
+		floor := &BigHex{}
+		floor.Mul(&baseFee.Int, big.NewInt(2))
+		floor.Add(&floor.Int, &r.MaxPriorityFeePerGas.Int)
+		r.MaxFeePerGas = maxBigHex(r.MaxFeePerGas, floor)
+	}
+	return r, nil
+}
+
+//Sets the fees given in wei, decimal or hex. The empty ones are left as proposed
+func (r *Replacement) SetFees(gasPrice, maxFee, tip string) error {
+	for _, f := range []struct {
+		value string
+		fee   **BigHex
+	}{{gasPrice, &r.GasPrice}, {maxFee, &r.MaxFeePerGas}, {tip, &r.MaxPriorityFeePerGas}} {
+		if f.value = strings.TrimSpace(f.value); len(f.value) == 0 {
+			continue
+		}
+		v := &BigHex{}
+		if err := v.UnmarshalJSON([]byte(f.value)); err != nil || v.Sign() < 0 {
+			return errors.New("not a valid fee: " + f.value)
+		}
+		*f.fee = v
+	}
+	return nil
+}
+
+//Tells whether the pools would take the replacement in place of the original
+func (r *Replacement) Check() error {
+	if r.Original.GasPrice == nil && !r.IsDynamicFee() {
+		return errors.New("the node does not tell the price paid by the transaction")
+	}
+	if !r.IsDynamicFee() {
+		if r.GasPrice == nil || r.GasPrice.Cmp(&r.MinGasPrice().Int) < 0 {
+			return errors.New("the gas price has to be at least " + r.MinGasPrice().String() + " wei to replace the transaction")
+		}
+		return nil
+	}
+	if r.MaxPriorityFeePerGas == nil || r.MaxPriorityFeePerGas.Cmp(&r.MinMaxPriorityFeePerGas().Int) < 0 {
+		return errors.New("the max priority fee has to be at least " + r.MinMaxPriorityFeePerGas().String() + " wei to replace the transaction")
+	}
+	if r.MaxFeePerGas == nil || r.MaxFeePerGas.Cmp(&r.MinMaxFeePerGas().Int) < 0 {
+		return errors.New("the max fee has to be at least " + r.MinMaxFeePerGas().String() + " wei to replace the transaction")
+	}
+	if r.MaxFeePerGas.Cmp(&r.MaxPriorityFeePerGas.Int) < 0 {
+		return errors.New("the max fee cannot be lower than the max priority fee")
+	}
+	return nil
+}
+
+//The transaction object of eth_sendTransaction
+func (r *Replacement) object() map[string]string {
+	tx := map[string]string{"from": r.Original.From, "data": r.Input, "gas": r.Gas.hex(), "nonce": r.Original.Nonce.hex()}
+	if r.Value != nil {
+		tx["value"] = "0x" + r.Value.Text(16)
+	}
+	if len(r.To) > 0 {
+		tx["to"] = r.To
+	}
+	if r.IsDynamicFee() {
+		tx["maxFeePerGas"] = "0x" + r.MaxFeePerGas.Text(16)
+		tx["maxPriorityFeePerGas"] = "0x" + r.MaxPriorityFeePerGas.Text(16)
+	} else {
+		tx["gasPrice"] = "0x" + r.GasPrice.Text(16)
+	}
+	return tx
+}
+
+//Sends the replacement through the node, which has to hold the key of the sender: unlocked for eth_sendTransaction,
+//or by the password for personal_sendTransaction. Returns the hash of the replacement
+func (rpcClient *Client) SendReplacement(node *Node, r *Replacement, password string) (string, error) {
+	if err := r.Check(); err != nil {
+		return "", err
+	}
+	var res interface{}
+	var err error
+	if len(password) > 0 {
+		res, err = rpcClient.explorerCall(node, "personal_sendTransaction", r.object(), password)
+	} else {
+		res, err = rpcClient.explorerCall(node, "eth_sendTransaction", r.object())
+	}
+	if err != nil {
+		return "", err
+	}
+	return string(*res.(*StringResult)), nil
+}
+
+//Where a replacement stands: either it or the original gets mined, as they share the nonce
+type ReplacementStatus struct {
+	Node        *Node
+	Sampled     MyTime
+	Original    *Transaction //nil once the node has dropped it
+	Replacement *Transaction //nil if the node does not know it
+	Receipt     *Receipt     //of the one mined
+}
+
+func (s *ReplacementStatus) IsMined() bool {
+	return s.Receipt != nil
+}
+
+//The replacement is mined, not the original
+func (s *ReplacementStatus) Replaced() bool {
+	return s.Receipt != nil && s.Replacement != nil && s.Receipt.TransactionHash == s.Replacement.Hash
+}
+
+//Reads both transactions from the node, and the receipt of the one mined
+func (rpcClient *Client) FollowReplacement(node *Node, original, replacement string) (*ReplacementStatus, error) {
+	st := &ReplacementStatus{Node: node, Sampled: MyTime(time.Now())}
+	calls := []*CallData{rpcClient.newNodeCall(node, "eth_getTransactionByHash", original),
+		rpcClient.newNodeCall(node, "eth_getTransactionByHash", replacement)}
+	for i, err := range rpcClient.callAll(calls...) {
+		if err == nil && calls[i].Response.Error != nil {
+			err = *calls[i].Response.Error
+		}
+		if err != nil {
+			return st, err
+		}
+		if tx, ok := calls[i].ParsedResult.(*Transaction); ok && calls[i].Parsed && len(tx.Hash) > 0 {
+			if i == 0 {
+				st.Original = tx
+			} else {
+				st.Replacement = tx
+			}
+		}
+	}
+	for _, tx := range []*Transaction{st.Replacement, st.Original} {
+		if tx != nil && !tx.IsPending() {
+			var err error
+			st.Receipt, err = rpcClient.GetReceipt(node, tx.Hash)
+			return st, err
+		}
+	}
+	return st, nil
+}
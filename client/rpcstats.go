@@ -0,0 +1,56 @@
+package client
+
+import (
+	"time"
+)
+
+//Counters of the calls to every endpoint, for the metrics. A batch is a single round trip
+type EndpointStats struct {
+	Requests    uint64        //round trips
+	Failures    uint64        //round trips failed in the transport (refused, timed out, bad http status)
+	RPCErrors   uint64        //calls answered with a JSON-RPC error
+	Latency     time.Duration //the total of the round trips
+	LastLatency time.Duration
+}
+
+func (rpcClient *Client) recordRoundTrip(endpoint string, took time.Duration, err error) {
+	rpcClient.mx.Lock()
+	defer rpcClient.mx.Unlock()
+	st := rpcClient.endpointStats(endpoint)
+	st.Requests++
+	if err != nil {
+		st.Failures++
+	}
+	st.Latency += took
+	st.LastLatency = took
+}
+
+func (rpcClient *Client) recordRPCError(endpoint string) {
+	rpcClient.mx.Lock()
+	defer rpcClient.mx.Unlock()
+	rpcClient.endpointStats(endpoint).RPCErrors++
+}
+
+//The caller holds the mx
+func (rpcClient *Client) endpointStats(endpoint string) *EndpointStats {
+	if rpcClient.rpcStats == nil {
+		rpcClient.rpcStats = map[string]*EndpointStats{}
+	}
+	st, ok := rpcClient.rpcStats[endpoint]
+	if !ok {
+		st = &EndpointStats{}
+		rpcClient.rpcStats[endpoint] = st
+	}
+	return st
+}
+
+//A copy of the counters, by the endpoint
+func (rpcClient *Client) EndpointStats() map[string]EndpointStats {
+	rpcClient.mx.RLock()
+	defer rpcClient.mx.RUnlock()
+	stats := make(map[string]EndpointStats, len(rpcClient.rpcStats))
+	for e, st := range rpcClient.rpcStats {
+		stats[e] = *st
+	}
+	return stats
+}
@@ -0,0 +1,305 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//Websocket subscriptions to the newHeads (and optionally newPendingTransactions) of every node with a WS endpoint.
+//The heads update the model as they arrive; the nodes without a WS endpoint, or with a broken subscription,
+//keep being polled over http.
+
+const wsPingInterval = 30 * time.Second
+const subscriptionsReconcileInterval = 10 * time.Second
+const maxSubscriptionBackoff = time.Minute
+
+type headSubscription struct {
+	nodeID      NodeID
+	endpoint    string
+	cancel      context.CancelFunc
+	mx          sync.Mutex
+	live        bool
+	head        *BlockNumberSample
+	pendingSeen int64
+	lastError   string
+	since       MyTime
+}
+
+//What the views get to know about a subscription
+type SubscriptionInfo struct {
+	Endpoint       string
+	Live           bool
+	Since          MyTime
+	Head           *BlockNumberSample
+	PendingTxsSeen int64
+	LastError      string
+}
+
+//The WS endpoint of the node: the RPC host with the default WS port. Empty if none
+func (rpcClient *Client) wsEndpoint(n *Node) string {
+	if len(rpcClient.DefaultWSPort) == 0 || len(n.RPCAddress) == 0 || strings.Contains(n.RPCAddress, "://") {
+		return ""
+	}
+	host := n.RPCAddress
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return host + ":" + rpcClient.DefaultWSPort
+}
+
+//Keeps a subscription running for every node of the model having a WS endpoint, until the context is done
+func (rpcClient *Client) StartSubscriptions(ctx context.Context) {
+	if rpcClient.MockMode || len(rpcClient.DefaultWSPort) == 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(subscriptionsReconcileInterval)
+		defer ticker.Stop()
+		for {
+			rpcClient.reconcileSubscriptions(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (rpcClient *Client) reconcileSubscriptions(ctx context.Context) {
+	rpcClient.subsMx.Lock()
+	defer rpcClient.subsMx.Unlock()
+	wanted := map[NodeID]string{}
+	for id, n := range rpcClient.NetModel().Nodes {
+		if ep := rpcClient.wsEndpoint(n); len(ep) > 0 {
+			wanted[id] = ep
+		}
+	}
+	for id, sub := range rpcClient.subs {
+		if wanted[id] != sub.endpoint {
+			sub.cancel()
+			delete(rpcClient.subs, id)
+		}
+	}
+	for id, ep := range wanted {
+		if _, running := rpcClient.subs[id]; running {
+			continue
+		}
+		subCtx, cancel := context.WithCancel(ctx)
+		sub := &headSubscription{nodeID: id, endpoint: ep, cancel: cancel}
+		rpcClient.subs[id] = sub
+		go rpcClient.runSubscription(subCtx, sub)
+	}
+}
+
+//Resubscribes with a backoff until the context is done
+func (rpcClient *Client) runSubscription(ctx context.Context, sub *headSubscription) {
+	backoff := time.Second
+	for {
+		err := rpcClient.followHeads(ctx, sub)
+		sub.mx.Lock()
+		sub.live = false
+		if err != nil {
+			sub.lastError = err.Error()
+		}
+		sub.mx.Unlock()
+		if ctx.Err() != nil {
+			return
+		}
+		rpcClient.log("subscription to " + sub.endpoint + " ended: " + sub.lastError)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > maxSubscriptionBackoff {
+			backoff = maxSubscriptionBackoff
+		}
+	}
+}
+
+//Subscribes and processes the notifications until the connection breaks or the context is done
+func (rpcClient *Client) followHeads(ctx context.Context, sub *headSubscription) error {
+	cfg := rpcClient.endpointConfig(sub.endpoint)
+	url := "ws://" + sub.endpoint
+	header := http.Header{}
+	var tlsConfig *tls.Config
+	if cfg != nil {
+		if cfg.secure() {
+			url = "wss://" + sub.endpoint
+		}
+		url = url + cfg.Path
+		cfg.authorize(header)
+		tlsConfig = cfg.tlsConfig
+	}
+	ws, err := dialWebSocket(url, header, tlsConfig, defaultTimeout)
+	if err != nil {
+		return err
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(wsPingInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				ws.Close()
+				return
+			case <-done:
+				ws.Close()
+				return
+			case <-ticker.C:
+				if ws.idle() > 2*wsPingInterval {
+					log.Println("websocket to", sub.endpoint, "went silent, closing")
+					ws.Close()
+					return
+				}
+				ws.Ping()
+			}
+		}
+	}()
+
+	//The heads are applied to the model apart from the reading, as applying them waits for the running probe.
+	//Only the latest head counts, so a head received meanwhile replaces the one waiting
+	heads := make(chan *BlockNumberSample, 1)
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case sample := <-heads:
+				rpcClient.updateNode(sub.nodeID, func(n *Node) {
+					n.recordBlockNumber(sample)
+				})
+			}
+		}
+	}()
+
+	kinds := map[uint]string{} //request id -> subscription kind
+	subscribe := func(kind string) error {
+		com := EthCommand{"2.0", "eth_subscribe", []interface{}{kind}, rpcClient.nextID()}
+		kinds[com.Id] = kind
+		jcom, _ := json.Marshal(com)
+		return ws.WriteText(jcom)
+	}
+	if err = subscribe("newHeads"); err != nil {
+		return err
+	}
+	if rpcClient.SubscribePendingTxs {
+		if err = subscribe("newPendingTransactions"); err != nil {
+			return err
+		}
+	}
+
+	subscriptions := map[string]string{} //subscription id -> kind
+	for {
+		msg, err := ws.ReadMessage()
+		if err != nil {
+			return err
+		}
+		var m wsMessage
+		if err = json.Unmarshal(msg, &m); err != nil {
+			rpcClient.log(err.Error())
+			continue
+		}
+		if m.ID != nil {
+			kind := kinds[*m.ID]
+			if m.Error != nil {
+				if kind == "newHeads" {
+					return errors.New("eth_subscribe: " + m.Error.Message)
+				}
+				log.Println(sub.endpoint, kind, "subscription refused:", m.Error.Message)
+				continue
+			}
+			var subID string
+			json.Unmarshal(m.Result, &subID)
+			subscriptions[subID] = kind
+			if kind == "newHeads" {
+				sub.mx.Lock()
+				sub.live = true
+				sub.since = MyTime(time.Now())
+				sub.lastError = ""
+				sub.mx.Unlock()
+				log.Println("Subscribed to the new heads of", sub.endpoint)
+			}
+			continue
+		}
+		if m.Method != "eth_subscription" {
+			continue
+		}
+		switch subscriptions[m.Params.Subscription] {
+		case "newHeads":
+			h := BlockHeader{}
+			if err = json.Unmarshal(m.Params.Result, &h); err != nil {
+				rpcClient.log(err.Error())
+				continue
+			}
+			sample := &BlockNumberSample{BlockNumber: h.Number}
+			sample.stamp()
+			sub.mx.Lock()
+			sub.head = sample
+			sub.mx.Unlock()
+			select {
+			case <-heads:
+			default:
+			}
+			heads <- sample
+		case "newPendingTransactions":
+			atomic.AddInt64(&sub.pendingSeen, 1)
+		}
+	}
+}
+
+type wsMessage struct {
+	ID     *uint           `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *EthError       `json:"error"`
+	Method string          `json:"method"`
+	Params struct {
+		Subscription string          `json:"subscription"`
+		Result       json.RawMessage `json:"result"`
+	} `json:"params"`
+}
+
+//The last head received over a live subscription
+func (rpcClient *Client) liveHead(id NodeID) (*BlockNumberSample, bool) {
+	rpcClient.subsMx.Lock()
+	sub, ok := rpcClient.subs[id]
+	rpcClient.subsMx.Unlock()
+	if !ok {
+		return nil, false
+	}
+	sub.mx.Lock()
+	defer sub.mx.Unlock()
+	return sub.head, sub.live && sub.head != nil
+}
+
+func (rpcClient *Client) HasLiveHeads(id NodeID) bool {
+	_, ok := rpcClient.liveHead(id)
+	return ok
+}
+
+//The state of the subscription to the node. Nil if there is none
+func (rpcClient *Client) Subscription(id NodeID) *SubscriptionInfo {
+	rpcClient.subsMx.Lock()
+	sub, ok := rpcClient.subs[id]
+	rpcClient.subsMx.Unlock()
+	if !ok {
+		return nil
+	}
+	sub.mx.Lock()
+	defer sub.mx.Unlock()
+	return &SubscriptionInfo{Endpoint: sub.endpoint, Live: sub.live, Since: sub.since, Head: sub.head,
+		PendingTxsSeen: atomic.LoadInt64(&sub.pendingSeen), LastError: sub.lastError}
+}
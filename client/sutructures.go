@@ -25,24 +25,52 @@ func Decode(respBytes []byte, data *CallData) error {
 
 	var p interface{}
 	switch data.Command.Method {
-	case "admin_datadir", "net_version", "web3_clientVersion", "parity_enode", "parity_nodeName": // All the single-strings results fall here
+	case "admin_datadir", "net_version", "web3_clientVersion", "eth_coinbase", "istanbul_nodeAddress", "eth_getCode",
+		"eth_call", "eth_sendTransaction", "personal_sendTransaction": // All the single-strings results fall here
 		s := StringResult("")
 		p = &s
-	case "parity_pendingTransactions":
-		p = &ParityPendingTxs{}
 	case "admin_peers":
 		p = &PeerArray{}
-	case "parity_netPeers":
-		p = &ParityPeerInfo{}
-	case "eth_blockNumber", "eth_call": //Result is not a struct, just an 0xdddd string representing a number
+	case "eth_blockNumber": //Result is not a struct, just an 0xdddd string representing a number
 		//b := HexString(0)
 		//p = &b
 		p = &BlockNumberSample{}
 	case "admin_nodeInfo":
 		p = &NodeInfo{}
+	case "eth_getBlockByNumber", "eth_getBlockByHash":
+		p = &Block{}
+	case "eth_getTransactionByHash", "eth_getTransactionByBlockNumberAndIndex", "eth_getTransactionByBlockHashAndIndex":
+		p = &Transaction{}
+	case "eth_getTransactionReceipt":
+		p = &Receipt{}
+	case "eth_getLogs":
+		p = &[]Log{}
+	case "eth_getBalance", "eth_gasPrice":
+		p = &BigHex{}
+	case "eth_getTransactionCount":
+		p = new(HexString)
 	case "txpool_status":
 		p = &TxpoolStatusSample{}
-
+	case "txpool_content":
+		p = &TxpoolContent{}
+	case "eth_mining":
+		p = new(bool)
+	case "eth_feeHistory":
+		p = &FeeHistory{}
+	case "eth_syncing":
+		p = &SyncStatus{}
+	case "clique_getSigners", "istanbul_getValidators", "qbft_getValidatorsByBlockNumber", "ibft_getValidatorsByBlockNumber":
+		p = &AddressList{}
+	case "clique_status", "istanbul_status":
+		p = &SealerStatus{}
+	case "clique_getSignerMetrics", "qbft_getSignerMetrics", "ibft_getSignerMetrics":
+		p = &SignerMetrics{}
+	case "clique_getSnapshot":
+		p = &CliqueSnapshot{}
+	case "istanbul_candidates", "qbft_getPendingVotes", "ibft_getPendingVotes", "clique_proposals":
+		p = &PendingVotes{}
+	default:
+		p = newClientResult(data.Command.Method)
 	}
 	if p != nil {
 		err = json.Unmarshal(data.Response.Result, p)
@@ -99,7 +127,8 @@ type PeerInfo struct {
 		LocalAddress  string `json:"localAddress"`  // Local endpoint of the TCP data connection
 		RemoteAddress string `json:"remoteAddress"` // Remote endpoint of the TCP data connection
 	} `json:"network"`
-	Protocols map[string]interface{} `json:"protocols"` // Sub-protocol specific metadata fields
+	Protocols map[string]interface{} `json:"protocols"`       // Sub-protocol specific metadata fields
+	Enode     string                 `json:"enode,omitempty"` // Not reported by the older geths
 }
 
 //Truncating the port number. This is needed so many times that the method is justified
@@ -110,20 +139,13 @@ func (p PeerInfo) RemoteHostMachine() string {
 //A type to hook the "parse()" method on. *This* is a ParseableResultType.
 type PeerArray []PeerInfo
 
-type ParityPeerInfo struct {
-	Active    int `json:active`
-	Connected int `json:connected`
-	Max       int `json:max`
-	Peers     PeerArray
-}
-
 //If the json "Result" is just a string
 type StringResult string
 
 //txpool_status structure - nothing appropriate found in geth :-(
 type TxpoolStatusSample struct {
-	Pending HexString `json:"pending",string`
-	Queued  HexString `json:"queued",string`
+	Pending HexString `json:"pending"`
+	Queued  HexString `json:"queued"`
 	Sampled MyTime    `json:"-"`
 }
 
@@ -131,14 +153,56 @@ func (txs *TxpoolStatusSample) stamp() {
 	txs.Sampled = MyTime(time.Now())
 }
 
-type ParityPendingTxs []interface{}
+//eth_syncing returns either false or the sync progress
+type SyncStatus struct {
+	Syncing       bool
+	StartingBlock HexString `json:"startingBlock"`
+	CurrentBlock  HexString `json:"currentBlock"`
+	HighestBlock  HexString `json:"highestBlock"`
+}
 
-func (ppt ParityPendingTxs) Len() int {
-	return len([]interface{}(ppt))
+func (ss *SyncStatus) UnmarshalJSON(raw []byte) error {
+	if string(raw) == "false" {
+		*ss = SyncStatus{}
+		return nil
+	}
+	type progress SyncStatus //no UnmarshalJSON on this one
+	p := progress{}
+	err := json.Unmarshal(raw, &p)
+	if err != nil {
+		return err
+	}
+	*ss = SyncStatus(p)
+	ss.Syncing = true
+	return nil
+}
+
+func (ss SyncStatus) String() string {
+	if !ss.Syncing {
+		return "in sync"
+	}
+	return fmt.Sprintf("syncing %v/%v", ss.CurrentBlock, ss.HighestBlock)
 }
 
 type HexString int64
 
+//Nethermind reports some of the counts as plain numbers
+func (h *HexString) UnmarshalJSON(raw []byte) error {
+	if string(raw) == "null" {
+		return nil
+	}
+	text, err := strconv.Unquote(string(raw))
+	if err != nil {
+		text = string(raw)
+	}
+	return h.UnmarshalText([]byte(text))
+}
+
+//The quantity as the RPC api takes it
+func (h HexString) hex() string {
+	return fmt.Sprintf("0x%x", int64(h))
+}
+
 func (h *HexString) UnmarshalText(text []byte) (err error) {
 	var tmpI int64
 	tmpI, err = strconv.ParseInt(string(text), 0, 64)
@@ -164,6 +228,14 @@ func (bns *BlockNumberSample) String() string {
 	return fmt.Sprintf("Block number: %v sampled at %s", bns.BlockNumber, bns.Sampled)
 }
 
+//The header fields of a newHeads notification toolsmith cares about
+type BlockHeader struct {
+	Number     HexString `json:"number"`
+	Hash       string    `json:"hash"`
+	ParentHash string    `json:"parentHash"`
+	Timestamp  HexString `json:"timestamp"`
+}
+
 //Hijacked from https://github.com/onrik/ethrpc/blob/master/ethrpc.go
 type EthResponse struct {
 	ID      int             `json:"id"`
@@ -178,6 +250,10 @@ type EthError struct {
 	Message string `json:"message"`
 }
 
+func (err EthError) Error() string {
+	return fmt.Sprintf("Error %d (%s)", err.Code, err.Message)
+}
+
 type EthCommand struct {
 	Jsonrpc string        `json:"jsonrpc"`
 	Method  string        `json:"method"`
@@ -0,0 +1,150 @@
+package client
+
+import (
+	"sync"
+	"time"
+)
+
+//The history of the node samples. Every probe publishing the model (rescans, heartbeats, bloops) appends its samples
+//to a bounded buffer per node and metric, the oldest samples overwritten first. The history is kept aside from
+//the model, as copying it with every clone would be a waste. With a store attached the samples are also written
+//to it (history.go) and read back on the next start
+
+const seriesLength = 720 //samples kept per node and metric, an hour at the default watchdog interval
+
+const MetricBlockNumber = "blocknumber"
+const MetricBlockTime = "blocktime" //seconds per block, derived from the block numbers
+const MetricPending = "pending"
+const MetricQueued = "queued"
+const MetricPeers = "peers"
+
+var Metrics = []string{MetricBlockNumber, MetricBlockTime, MetricPending, MetricQueued, MetricPeers, MetricBaseFee, MetricGasUsed}
+
+type Point struct {
+	Time  MyTime
+	Value float64
+}
+
+//A fixed size buffer of the latest points
+type ring struct {
+	points []Point
+	next   int //where the next point goes once the buffer is full
+}
+
+//Returns false if the point is not new
+func (r *ring) add(p Point) bool {
+	if last, ok := r.last(); ok && !time.Time(p.Time).After(time.Time(last.Time)) {
+		return false //the same sample seen by another probe, or one read back from the store
+	}
+	if len(r.points) < seriesLength {
+		r.points = append(r.points, p)
+		return true
+	}
+	r.points[r.next] = p
+	r.next = (r.next + 1) % seriesLength
+	return true
+}
+
+func (r *ring) last() (Point, bool) {
+	if len(r.points) == 0 {
+		return Point{}, false
+	}
+	if len(r.points) < seriesLength {
+		return r.points[len(r.points)-1], true
+	}
+	return r.points[(r.next+seriesLength-1)%seriesLength], true
+}
+
+//The points, the oldest first
+func (r *ring) ordered() []Point {
+	out := make([]Point, 0, len(r.points))
+	out = append(out, r.points[r.next:]...)
+	return append(out, r.points[:r.next]...)
+}
+
+type seriesStore struct {
+	mx     sync.Mutex
+	series map[NodeID]map[string]*ring
+	peers  map[NodeID]map[NodeID]bool //the peers last written to the store
+}
+
+func (ss *seriesStore) add(id NodeID, metric string, p Point) bool {
+	if ss.series == nil {
+		ss.series = map[NodeID]map[string]*ring{}
+	}
+	if ss.series[id] == nil {
+		ss.series[id] = map[string]*ring{}
+	}
+	r, ok := ss.series[id][metric]
+	if !ok {
+		r = &ring{}
+		ss.series[id][metric] = r
+	}
+	return r.add(p)
+}
+
+func (ss *seriesStore) get(id NodeID, metric string) []Point {
+	ss.mx.Lock()
+	defer ss.mx.Unlock()
+	if metric == MetricBlockTime {
+		return blockTimes(ss.get0(id, MetricBlockNumber))
+	}
+	return ss.get0(id, metric)
+}
+
+//The caller holds the mx
+func (ss *seriesStore) get0(id NodeID, metric string) []Point {
+	r, ok := ss.series[id][metric]
+	if !ok {
+		return nil
+	}
+	return r.ordered()
+}
+
+//Appends the samples of the model to the history
+func (rpcClient *Client) recordSamples(bcn *BlockchainNet) {
+	ss := &rpcClient.series
+	ss.mx.Lock()
+	defer ss.mx.Unlock()
+	add := func(id NodeID, metric string, p Point) {
+		if ss.add(id, metric, p) {
+			rpcClient.storeSample(id, metric, p)
+		}
+	}
+	for id, n := range bcn.Nodes {
+		if s := n.LastBlockNumberSample; s != nil {
+			add(id, MetricBlockNumber, Point{s.Sampled, float64(s.BlockNumber)})
+		}
+		if s := n.TxpoolStatus; s != nil {
+			add(id, MetricPending, Point{s.Sampled, float64(s.Pending)})
+			add(id, MetricQueued, Point{s.Sampled, float64(s.Queued)})
+		}
+		if n.IsReachable() {
+			add(id, MetricPeers, Point{n.LastReach, float64(len(n.Peers))})
+			rpcClient.storePeerChanges(n)
+		}
+	}
+}
+
+//The history of the metric on the node, the oldest first
+func (rpcClient *Client) Series(id NodeID, metric string) []Point {
+	return rpcClient.series.get(id, metric)
+}
+
+//The average time between the blocks, each time the block number has gone up.
+//Measured from the first sample of the previous number, not the last one
+func blockTimes(numbers []Point) []Point {
+	var times []Point
+	since := 0
+	for i := 1; i < len(numbers); i++ {
+		if numbers[i].Value == numbers[since].Value {
+			continue
+		}
+		if blocks := numbers[i].Value - numbers[since].Value; blocks > 0 {
+			secs := time.Time(numbers[i].Time).Sub(time.Time(numbers[since].Time)).Seconds()
+			times = append(times, Point{numbers[i].Time, secs / blocks})
+		}
+		since = i
+	}
+	return times
+}
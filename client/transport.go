@@ -0,0 +1,117 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+//A transport gets a raw JSON-RPC payload (a single command or a batch) to the endpoint and the response back.
+//The endpoint address decides the transport:
+//	host[:port]             - http(s), the default RPC port assumed if none given. See EndpointConfig
+//	ipc:///path/to/geth.ipc - the unix socket of a co-located node
+type transport interface {
+	roundTrip(endpoint string, payload []byte) ([]byte, error)
+}
+
+const ipcScheme = "ipc://"
+
+func isIPCAddress(endpoint string) bool {
+	return strings.HasPrefix(endpoint, ipcScheme)
+}
+
+func (rpcClient *Client) transportFor(endpoint string) transport {
+	if isIPCAddress(endpoint) {
+		return ipcTransport{timeout: defaultTimeout}
+	}
+	return httpTransport{rpcClient}
+}
+
+type httpTransport struct {
+	rpcClient *Client
+}
+
+func (t httpTransport) roundTrip(endpoint string, payload []byte) ([]byte, error) {
+	rpcClient := t.rpcClient
+	cfg := rpcClient.endpointConfig(endpoint)
+	//TODO: allow to define and memorize node-specific ports
+	host := endpoint
+	if !strings.Contains(host, ":") {
+		host = host + ":" + rpcClient.DefaultRPCPort
+	}
+	if cfg.secure() {
+		host = "https://" + host
+	} else {
+		host = "http://" + host
+	}
+	if cfg != nil {
+		host = host + cfg.Path
+	}
+
+	req, err := http.NewRequest("POST", host, bytes.NewReader(payload))
+	if err != nil {
+		rpcClient.log(fmt.Sprintf("%s", err))
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", rpcClient.UserAgent)
+	req.Header.Set("Content-type", "application/json")
+	cfg.authorize(req.Header)
+	httpClient := rpcClient.httpClient
+	if cfg != nil && cfg.httpClient != nil && !rpcClient.MockMode {
+		httpClient = cfg.httpClient
+	}
+	resp, err := httpClient.Do(req)
+
+	if err != nil {
+		log.Println(err)
+		//rpcClient.NetModel.UnreachableNodes[GhostNode(host)] = MyTime(time.Now())
+		return nil, transportError{err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		err = httpStatusError{resp.StatusCode, resp.Status}
+		return nil, err
+	}
+	respBytes, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		rpcClient.log(fmt.Sprintf("%s", err))
+		return nil, err
+	}
+	rpcClient.log("Returned:\n" + fmt.Sprintf("%s", resp.Header))
+	return respBytes, nil
+}
+
+//One connection per call - the node serves as many as it is given, and the calls are not that frequent
+type ipcTransport struct {
+	timeout time.Duration
+}
+
+func (t ipcTransport) roundTrip(endpoint string, payload []byte) ([]byte, error) {
+	path := strings.TrimPrefix(endpoint, ipcScheme)
+	conn, err := net.DialTimeout("unix", path, t.timeout)
+	if err != nil {
+		log.Println(err)
+		return nil, transportError{err}
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(t.timeout))
+	_, err = conn.Write(payload)
+	if err != nil {
+		return nil, transportError{err}
+	}
+	//The socket is a stream - the response ends where the JSON value does
+	var resp json.RawMessage
+	err = json.NewDecoder(conn).Decode(&resp)
+	if err != nil {
+		return nil, transportError{err}
+	}
+	return resp, nil
+}
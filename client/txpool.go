@@ -0,0 +1,255 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+//The analysis of the transactions in a node's pool, from txpool_content (Geth, Erigon, Nethermind and the like).
+//The transactions of each sender are lined up by nonce against the account nonce on the chain, which shows
+//the nonce gaps keeping the later transactions queued. A transaction counts as underpriced only below the base fee,
+//which no block takes less than; before London the node's eth_gasPrice is just a suggestion, so the transactions
+//paying less are only told as such. The pool does not tell how long a transaction has been waiting, so the age is
+//counted from when a transaction was first seen in the pool here
+
+const stuckTxAge = 5 * time.Minute
+
+//What txpool_content returns: the transactions by sender and nonce
+type TxpoolContent struct {
+	Pending map[string]map[string]*Transaction `json:"pending"`
+	Queued  map[string]map[string]*Transaction `json:"queued"`
+}
+
+type PoolTx struct {
+	*Transaction
+	Queued         bool
+	FirstSeen      MyTime
+	Underpriced    bool   //its fee cap is below the base fee
+	BelowSuggested bool   //with no base fee, its gas price is below what eth_gasPrice suggests, which may still do
+	Reason         string //why a queued transaction is not executable, if it can be told
+}
+
+func (ptx *PoolTx) Age() time.Duration {
+	return time.Since(time.Time(ptx.FirstSeen)).Round(time.Second)
+}
+
+//Waiting for longer than it should on a working network
+func (ptx *PoolTx) IsStuck() bool {
+	return ptx.Age() > stuckTxAge
+}
+
+//The fee cap: the max fee per gas of the dynamic fee transactions, the gas price of the others
+func (ptx *PoolTx) FeeCap() *BigHex {
+	if ptx.MaxFeePerGas != nil {
+		return ptx.MaxFeePerGas
+	}
+	return ptx.GasPrice
+}
+
+//The nonces missing between From and To, both included
+type NonceGap struct {
+	From HexString
+	To   HexString
+}
+
+type SenderPool struct {
+	Address      string
+	AccountNonce HexString //the next nonce the chain expects of the sender
+	Txs          []*PoolTx //by nonce
+	Gaps         []NonceGap
+	Pending      int
+	Queued       int
+	Stale        int //with a nonce already used on the chain
+}
+
+func (sp *SenderPool) HasIssues() bool {
+	return len(sp.Gaps) > 0 || sp.Stale > 0
+}
+
+type TxpoolReport struct {
+	Node              *Node
+	Sampled           MyTime
+	BaseFee           *BigHex //of the latest block, nil before London
+	GasPrice          *BigHex //what eth_gasPrice suggests
+	Senders           []*SenderPool
+	Pending           int
+	Queued            int
+	QueuedByGap       int //queued behind a nonce gap
+	QueuedUnderpriced int //queued with the fee cap too low
+	Underpriced       int
+	BelowSuggested    int
+	Stuck             int
+	StuckAge          time.Duration //the age over which a transaction counts as stuck
+}
+
+//The queued transactions neither behind a gap nor underpriced
+func (r *TxpoolReport) QueuedUnexplained() int {
+	return r.Queued - r.QueuedByGap - r.QueuedUnderpriced
+}
+
+func (rpcClient *Client) TxpoolContent(node *Node) (*TxpoolContent, error) {
+	res, err := rpcClient.explorerCall(node, "txpool_content")
+	if err != nil {
+		return nil, err
+	}
+	return res.(*TxpoolContent), nil
+}
+
+//Reads the pool of the node and analyses it
+func (rpcClient *Client) TxpoolReport(node *Node) (*TxpoolReport, error) {
+	content, err := rpcClient.TxpoolContent(node)
+	if err != nil {
+		return nil, err
+	}
+	return rpcClient.AnalyzeTxpool(node, content)
+}
+
+//Lines up the transactions of each sender against its account nonce, and tells the gaps
+//and the transactions which pay too little
+func (rpcClient *Client) AnalyzeTxpool(node *Node, content *TxpoolContent) (*TxpoolReport, error) {
+	report := &TxpoolReport{Node: node, Sampled: MyTime(time.Now()), StuckAge: stuckTxAge}
+	senders := map[string]*SenderPool{}
+	add := func(txs map[string]map[string]*Transaction, queued bool) {
+		for addr, byNonce := range txs {
+			sp, ok := senders[addr]
+			if !ok {
+				sp = &SenderPool{Address: addr}
+				senders[addr] = sp
+			}
+			for nonce, tx := range byNonce {
+				if n, err := strconv.ParseUint(nonce, 10, 63); err == nil && tx.Nonce == 0 {
+					tx.Nonce = HexString(n)
+				}
+				sp.Txs = append(sp.Txs, &PoolTx{Transaction: tx, Queued: queued})
+			}
+		}
+	}
+	add(content.Pending, false)
+	add(content.Queued, true)
+
+	//The account nonces, the base fee and the gas price in one batch
+	var calls []*CallData
+	for _, sp := range senders {
+		sp.AccountNonce = -1
+		calls = append(calls, rpcClient.newNodeCall(node, "eth_getTransactionCount", sp.Address, "latest"))
+	}
+	head := rpcClient.newNodeCall(node, "eth_getBlockByNumber", "latest", false)
+	gasPrice := rpcClient.newNodeCall(node, "eth_gasPrice")
+	calls = append(calls, head, gasPrice)
+	errs := rpcClient.callAll(calls...)
+	if errs[len(errs)-2] != nil {
+		return nil, errs[len(errs)-2]
+	}
+	var nonceErr error
+	for i, call := range calls[:len(senders)] {
+		err := errs[i]
+		if err == nil && call.Response.Error != nil {
+			err = *call.Response.Error
+		}
+		if err == nil && call.Parsed {
+			senders[call.Command.Params[0].(string)].AccountNonce = *call.ParsedResult.(*HexString)
+		} else if nonceErr == nil {
+			nonceErr = errors.New("could not read the account nonces, the gaps before the pool are not told: " + fmt.Sprint(err))
+		}
+	}
+	if b, ok := head.ParsedResult.(*Block); ok && head.Parsed {
+		report.BaseFee = b.BaseFeePerGas
+	}
+	if gp, ok := gasPrice.ParsedResult.(*BigHex); ok && gasPrice.Parsed {
+		report.GasPrice = gp
+	}
+
+	var hashes []string
+	for _, sp := range senders {
+		for _, ptx := range sp.Txs {
+			hashes = append(hashes, ptx.Hash)
+		}
+	}
+	seen := rpcClient.markPoolSeen(node.ID, hashes)
+	for _, sp := range senders {
+		sort.Slice(sp.Txs, func(i, j int) bool { return sp.Txs[i].Nonce < sp.Txs[j].Nonce })
+		next := sp.AccountNonce
+		for _, ptx := range sp.Txs {
+			ptx.FirstSeen = seen[ptx.Hash]
+			ptx.Underpriced, ptx.BelowSuggested = report.underpriced(ptx)
+			if next >= 0 && ptx.Nonce < sp.AccountNonce {
+				sp.Stale++
+			} else if next >= 0 && ptx.Nonce > next {
+				sp.Gaps = append(sp.Gaps, NonceGap{From: next, To: ptx.Nonce - 1})
+			}
+			if ptx.Nonce >= next {
+				next = ptx.Nonce + 1
+			}
+			switch {
+			case ptx.Queued && len(sp.Gaps) > 0:
+				ptx.Reason = "nonce gap"
+				report.QueuedByGap++
+			case ptx.Queued && ptx.Underpriced:
+				ptx.Reason = "underpriced"
+				report.QueuedUnderpriced++
+			}
+			if ptx.Queued {
+				sp.Queued++
+			} else {
+				sp.Pending++
+			}
+			if ptx.Underpriced {
+				report.Underpriced++
+			}
+			if ptx.BelowSuggested {
+				report.BelowSuggested++
+			}
+			if ptx.IsStuck() {
+				report.Stuck++
+			}
+		}
+		report.Pending += sp.Pending
+		report.Queued += sp.Queued
+		report.Senders = append(report.Senders, sp)
+	}
+	//The senders with the most queued first, as those explain a growing queue
+	sort.Slice(report.Senders, func(i, j int) bool {
+		a, b := report.Senders[i], report.Senders[j]
+		if a.Queued != b.Queued {
+			return a.Queued > b.Queued
+		}
+		return a.Address < b.Address
+	})
+	return report, nonceErr
+}
+
+//Below the base fee, or with no base fee, below the suggested gas price
+func (r *TxpoolReport) underpriced(ptx *PoolTx) (underpriced bool, belowSuggested bool) {
+	if ptx.FeeCap() == nil {
+		return
+	}
+	if r.BaseFee != nil {
+		return ptx.FeeCap().Cmp(&r.BaseFee.Int) < 0, false
+	}
+	return false, r.GasPrice != nil && ptx.FeeCap().Cmp(&r.GasPrice.Int) < 0
+}
+
+//Remembers when each transaction of the node's pool was first seen, and forgets those which have left it.
+//Returns the first seen times
+func (rpcClient *Client) markPoolSeen(id NodeID, hashes []string) map[string]MyTime {
+	now := MyTime(time.Now())
+	rpcClient.mx.Lock()
+	defer rpcClient.mx.Unlock()
+	if rpcClient.poolSeen == nil {
+		rpcClient.poolSeen = map[NodeID]map[string]MyTime{}
+	}
+	old := rpcClient.poolSeen[id]
+	seen := map[string]MyTime{}
+	for _, hash := range hashes {
+		if t, ok := old[hash]; ok {
+			seen[hash] = t
+		} else {
+			seen[hash] = now
+		}
+	}
+	rpcClient.poolSeen[id] = seen
+	return seen
+}
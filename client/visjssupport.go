@@ -5,6 +5,7 @@ import (
 	"html/template"
 	"log"
 	"sort"
+	"time"
 )
 
 func (bcn *BlockchainNet) VisjsNodes() template.JS {
@@ -39,8 +40,10 @@ func (bcn *BlockchainNet) GetJsonNodes() []Visnode {
 	return vn
 }
 
+//The bridges, the links whose loss would split the network, are drawn in red
 func (bcn *BlockchainNet) VisjsEdges() template.JS {
 	var ve []Visedge
+	gr := bcn.AnalyzeGraph()
 	for _, nd := range bcn.Nodes {
 		if !nd.IsReachable() {
 			continue
@@ -50,7 +53,12 @@ func (bcn *BlockchainNet) VisjsEdges() template.JS {
 
 				retAddr, _ := bcn.Nodes[pnd.ID].PeerSeenAs(nd.ID)
 				forAddr, _ := bcn.Nodes[nd.ID].PeerSeenAs(pnd.ID)
-				ve = append(ve, VisjsEdge(nd, forAddr+"<->"+retAddr, pnd))
+				e := VisjsEdge(nd, forAddr+"<->"+retAddr, pnd)
+				if gr.IsBridge(nd.ID, pnd.ID) {
+					e.Color.Color = "red"
+					e.Color.Highlight = "red"
+				}
+				ve = append(ve, e)
 			}
 		}
 
@@ -63,6 +71,26 @@ func (bcn *BlockchainNet) VisjsEdges() template.JS {
 	return template.JS(ret)
 }
 
+//The history of the metric as the Graph2d items and groups, a group (line) per node
+func (rpcClient *Client) VisjsSeries(metric string) template.JS {
+	data := Visseries{Items: []Visitem{}, Groups: []Visgroup{}}
+	for _, nd := range rpcClient.NetModel().sortedNodes() {
+		points := rpcClient.Series(nd.ID, metric)
+		if len(points) == 0 {
+			continue
+		}
+		data.Groups = append(data.Groups, Visgroup{Id: nd.ID, Content: nd.ShortName})
+		for _, p := range points {
+			data.Items = append(data.Items, Visitem{X: time.Time(p.Time).Format(time.RFC3339), Y: p.Value, Group: nd.ID})
+		}
+	}
+	ret, err := json.Marshal(data)
+	if err != nil {
+		log.Println(err)
+	}
+	return template.JS(ret)
+}
+
 func VisjsEdge(base *Node, addr string, peer *Node) Visedge {
 	e := Visedge{From: base.ID, To: peer.ID, Label: addr}
 	e.Color.Color = "blue"
@@ -103,3 +131,19 @@ type Font struct {
 	Size  int    `json:"size,omitempty"`
 	Align string `json:"align,omitempty"`
 }
+
+type Visseries struct {
+	Items  []Visitem  `json:"items"`
+	Groups []Visgroup `json:"groups"`
+}
+
+type Visitem struct {
+	X     string  `json:"x"`
+	Y     float64 `json:"y"`
+	Group NodeID  `json:"group"`
+}
+
+type Visgroup struct {
+	Id      NodeID `json:"id"`
+	Content string `json:"content"`
+}
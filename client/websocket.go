@@ -0,0 +1,245 @@
+package client
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//A minimal RFC 6455 websocket client - just enough to talk JSON-RPC to a node.
+//Written here to keep toolsmith free of dependencies.
+
+const wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+const wsMaxMessage = 32 << 20
+
+const (
+	wsContinuation = 0x0
+	wsText         = 0x1
+	wsBinary       = 0x2
+	wsClose        = 0x8
+	wsPing         = 0x9
+	wsPong         = 0xA
+)
+
+var errWSClosed = errors.New("websocket closed by the peer")
+
+type wsConn struct {
+	conn     net.Conn
+	br       *bufio.Reader
+	wmx      sync.Mutex //frames are written by the reader (pongs) and the writer
+	lastRead int64      //unix nanos of the last frame read
+}
+
+//Dials a ws:// or wss:// url and performs the opening handshake
+func dialWebSocket(rawurl string, header http.Header, tlsConfig *tls.Config, timeout time.Duration) (*wsConn, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	host := u.Host
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		if u.Scheme == "wss" {
+			host = host + ":443"
+		} else {
+			host = host + ":80"
+		}
+	}
+	conn, err := net.DialTimeout("tcp", host, timeout)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "wss" {
+		cfg := &tls.Config{}
+		if tlsConfig != nil {
+			cfg = tlsConfig.Clone()
+		}
+		if len(cfg.ServerName) == 0 {
+			cfg.ServerName = u.Hostname()
+		}
+		tconn := tls.Client(conn, cfg)
+		conn = tconn
+	}
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	nonce := make([]byte, 16)
+	rand.Read(nonce)
+	key := base64.StdEncoding.EncodeToString(nonce)
+	hu := *u
+	hu.Scheme = "http"
+	req, err := http.NewRequest("GET", hu.String(), nil)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	for k, vs := range header {
+		for _, v := range vs {
+			req.Header.Add(k, v)
+		}
+	}
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	err = req.Write(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		conn.Close()
+		return nil, errors.New("websocket handshake failed: " + resp.Status)
+	}
+	sum := sha1.Sum([]byte(key + wsGUID))
+	if resp.Header.Get("Sec-WebSocket-Accept") != base64.StdEncoding.EncodeToString(sum[:]) {
+		conn.Close()
+		return nil, errors.New("websocket handshake failed: bad Sec-WebSocket-Accept")
+	}
+	conn.SetDeadline(time.Time{})
+	return &wsConn{conn: conn, br: br, lastRead: time.Now().UnixNano()}, nil
+}
+
+func (ws *wsConn) WriteText(payload []byte) error {
+	return ws.writeFrame(wsText, payload)
+}
+
+//Client frames are always masked
+func (ws *wsConn) writeFrame(opcode byte, payload []byte) error {
+	ws.wmx.Lock()
+	defer ws.wmx.Unlock()
+	header := []byte{0x80 | opcode, 0}
+	l := len(payload)
+	switch {
+	case l < 126:
+		header[1] = byte(l)
+	case l <= 0xFFFF:
+		header[1] = 126
+		header = append(header, 0, 0)
+		binary.BigEndian.PutUint16(header[2:], uint16(l))
+	default:
+		header[1] = 127
+		header = append(header, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(header[2:], uint64(l))
+	}
+	header[1] |= 0x80
+	mask := make([]byte, 4)
+	rand.Read(mask)
+	header = append(header, mask...)
+	masked := make([]byte, l)
+	for i := range payload {
+		masked[i] = payload[i] ^ mask[i%4]
+	}
+	_, err := ws.conn.Write(append(header, masked...))
+	return err
+}
+
+//Returns the next complete text or binary message. Answers pings on the way
+func (ws *wsConn) ReadMessage() ([]byte, error) {
+	var message []byte
+	for {
+		fin, opcode, payload, err := ws.readFrame()
+		if err != nil {
+			return nil, err
+		}
+		switch opcode {
+		case wsPing:
+			err = ws.writeFrame(wsPong, payload)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		case wsPong:
+			continue
+		case wsClose:
+			ws.writeFrame(wsClose, nil)
+			return nil, errWSClosed
+		case wsText, wsBinary, wsContinuation:
+			message = append(message, payload...)
+			if len(message) > wsMaxMessage {
+				return nil, errors.New("websocket message too large")
+			}
+			if fin {
+				return message, nil
+			}
+		default:
+			return nil, errors.New("unexpected websocket opcode")
+		}
+	}
+}
+
+func (ws *wsConn) readFrame() (fin bool, opcode byte, payload []byte, err error) {
+	head := make([]byte, 2)
+	if _, err = io.ReadFull(ws.br, head); err != nil {
+		return
+	}
+	atomic.StoreInt64(&ws.lastRead, time.Now().UnixNano())
+	fin = head[0]&0x80 != 0
+	opcode = head[0] & 0x0F
+	masked := head[1]&0x80 != 0
+	l := uint64(head[1] & 0x7F)
+	switch l {
+	case 126:
+		ext := make([]byte, 2)
+		if _, err = io.ReadFull(ws.br, ext); err != nil {
+			return
+		}
+		l = uint64(binary.BigEndian.Uint16(ext))
+	case 127:
+		ext := make([]byte, 8)
+		if _, err = io.ReadFull(ws.br, ext); err != nil {
+			return
+		}
+		l = binary.BigEndian.Uint64(ext)
+	}
+	if l > wsMaxMessage {
+		err = errors.New("websocket frame too large")
+		return
+	}
+	var mask []byte
+	if masked {
+		mask = make([]byte, 4)
+		if _, err = io.ReadFull(ws.br, mask); err != nil {
+			return
+		}
+	}
+	payload = make([]byte, l)
+	if _, err = io.ReadFull(ws.br, payload); err != nil {
+		return
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return
+}
+
+func (ws *wsConn) Ping() error {
+	return ws.writeFrame(wsPing, nil)
+}
+
+//Time since the last frame has been read
+func (ws *wsConn) idle() time.Duration {
+	return time.Since(time.Unix(0, atomic.LoadInt64(&ws.lastRead)))
+}
+
+func (ws *wsConn) Close() error {
+	ws.writeFrame(wsClose, nil)
+	return ws.conn.Close()
+}
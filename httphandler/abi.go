@@ -0,0 +1,126 @@
+package httphandler
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/san-lab/toolsmith/abi"
+	"github.com/san-lab/toolsmith/client"
+	"github.com/san-lab/toolsmith/templates"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+//The contract pages: the functions of a contract from its ABI, with the forms to call them
+
+type contractPageData struct {
+	Contract *abi.Contract
+	Node     *client.Node
+	Call     *abi.DecodedCall //the call just made, with what it returned
+	TxHash   string           //of the transaction just sent
+}
+
+func (lhh *LilHttpHandler) contractPage(comm string, r *http.Request) (*contractPageData, error) {
+	page := &contractPageData{}
+	c := lhh.rpcClient.ABIs().Contract(r.FormValue(validatorparamname))
+	if c == nil {
+		return page, errors.New("no ABI for the address " + r.FormValue(validatorparamname))
+	}
+	page.Contract = c
+	node, err := lhh.rpcClient.ExplorerNode(r.FormValue(nodeparamname))
+	if err != nil {
+		return page, err
+	}
+	page.Node = node
+	if comm != abicall {
+		return page, nil
+	}
+	f, err := c.Parsed().Function(r.FormValue(fnparamname))
+	if err != nil {
+		return page, err
+	}
+	args := make([]string, len(f.Inputs))
+	for i := range args {
+		args[i] = r.FormValue("arg" + strconv.Itoa(i))
+	}
+	data, err := f.EncodeCall(args)
+	if err != nil {
+		return page, err
+	}
+	input := "0x" + hex.EncodeToString(data)
+	if r.FormValue(sendparamname) == "true" {
+		page.TxHash, err = lhh.rpcClient.SendTransaction(node, r.FormValue(fromparamname), c.Address, data, r.FormValue(valueparamname))
+		page.Call = lhh.rpcClient.ABIs().DecodeCall(c.Address, input)
+		return page, err
+	}
+	out, err := lhh.rpcClient.EthCall(node, r.FormValue(fromparamname), c.Address, data, r.FormValue(valueparamname))
+	if err != nil {
+		page.Call = lhh.rpcClient.ABIs().DecodeCall(c.Address, input)
+		return page, err
+	}
+	page.Call = lhh.rpcClient.ABIs().DecodeResult(c.Address, input, out)
+	return page, nil
+}
+
+//A passthrough parameter: a JSON object or array (the transaction of eth_call, the filter of eth_getLogs),
+//a bool, or the string as it is
+func passthroughParam(s string) interface{} {
+	switch {
+	case s == "true", s == "false":
+		return s == "true"
+	case strings.HasPrefix(s, "{"), strings.HasPrefix(s, "["):
+		var v interface{}
+		if json.Unmarshal([]byte(s), &v) == nil {
+			return v
+		}
+	}
+	return s
+}
+
+//An eth_call or eth_getLogs result with what the ABIs decode of it
+type decodedResult struct {
+	Call    *client.CallData
+	Decoded *abi.DecodedCall
+	Logs    []decodedLog
+}
+
+type decodedLog struct {
+	client.Log
+	Decoded *abi.DecodedLog
+}
+
+//The decoded page of an eth_call or eth_getLogs call, the raw one if no ABI decodes anything of it
+func decodedCallResult(abis *abi.Registry, callData *client.CallData) (string, interface{}) {
+	if !callData.Parsed {
+		return templates.Raw, callData
+	}
+	res := &decodedResult{Call: callData}
+	switch result := callData.ParsedResult.(type) {
+	case *client.StringResult:
+		if len(callData.Command.Params) == 0 {
+			break
+		}
+		tx, _ := callData.Command.Params[0].(map[string]interface{})
+		to, _ := tx["to"].(string)
+		input, ok := tx["data"].(string)
+		if !ok {
+			input, _ = tx["input"].(string)
+		}
+		res.Decoded = abis.DecodeResult(to, input, string(*result))
+		if res.Decoded != nil {
+			return templates.Decoded, res
+		}
+	case *[]client.Log:
+		found := false
+		for _, l := range *result {
+			dl := decodedLog{Log: l, Decoded: abis.DecodeLog(l.Address, l.Topics, l.Data)}
+			found = found || dl.Decoded != nil
+			res.Logs = append(res.Logs, dl)
+		}
+		if found {
+			return templates.Decoded, res
+		}
+	}
+	return templates.Raw, callData
+}
@@ -0,0 +1,433 @@
+package httphandler
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/san-lab/toolsmith/client"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+//The JSON API under /api/v1, for the automation. The resources call the same client and watchdog methods
+//as the HTML commands; the errors come back as {"error": "..."} with the matching HTTP status
+//
+//	GET    /api/v1/network                              the network summary
+//	POST   /api/v1/network/discover                     rediscovers the network
+//	POST   /api/v1/network/rescan                       rescans the known nodes
+//	POST   /api/v1/network/heartbeat                    rescans and tells the progress
+//	POST   /api/v1/network/bloop                        samples the block numbers
+//	GET    /api/v1/network/discovery                    the progress of the discovery
+//	GET    /api/v1/nodes                                the nodes
+//	GET    /api/v1/nodes/{id or name}                   a node
+//	GET    /api/v1/nodes/{id or name}/peers             its peers
+//	GET    /api/v1/watchdog                             the watchdog status and settings
+//	PUT    /api/v1/watchdog/config                      {"interval": s, "threshold": s, "reorgDepth": n, "fullBlocks": n, "maxBaseFee": gwei}, any of them
+//	PUT    /api/v1/watchdog/state                       {"state": "OK"}, to acknowledge an issue
+//	GET    /api/v1/watchdog/recipients
+//	POST   /api/v1/watchdog/recipients                  {"email": "..."}
+//	DELETE /api/v1/watchdog/recipients/{email}
+//	POST   /api/v1/watchdog/recipients/{email}/block
+//	PUT    /api/v1/password                             {"password": "..."}, for the authenticated user
+
+const apiPrefix = "api"
+const apiVersion = "v1"
+
+type apiError struct {
+	Error string `json:"error"`
+}
+
+type apiNode struct {
+	ID            client.NodeID      `json:"id"`
+	Name          string             `json:"name"`
+	FullName      string             `json:"fullName"`
+	ClientType    string             `json:"clientType"`
+	ClientVersion string             `json:"clientVersion"`
+	Enode         string             `json:"enode"`
+	RPCAddress    string             `json:"rpcAddress"`
+	Addresses     []string           `json:"addresses"`
+	Reachable     bool               `json:"reachable"`
+	Stuck         bool               `json:"stuck"`
+	LastReach     client.MyTime      `json:"lastReach"`
+	BlockNumber   *int64             `json:"blockNumber,omitempty"`
+	BlockSampled  client.MyTime      `json:"blockSampled"`
+	Pending       *int64             `json:"pending,omitempty"`
+	Queued        *int64             `json:"queued,omitempty"`
+	Syncing       *client.SyncStatus `json:"syncing,omitempty"`
+	Consensus     string             `json:"consensus,omitempty"`
+	Peers         []client.NodeID    `json:"peers"`
+}
+
+type apiPeer struct {
+	ID     client.NodeID `json:"id"`
+	Name   string        `json:"name"`
+	SeenAs string        `json:"seenAs"`
+	Known  bool          `json:"known"` //the peer is a node of the model
+}
+
+type apiNetwork struct {
+	NetworkID            string                   `json:"networkId"`
+	AccessNode           client.NodeID            `json:"accessNode"`
+	Nodes                int                      `json:"nodes"`
+	Reachable            int                      `json:"reachable"`
+	Stuck                int                      `json:"stuck"`
+	MaxBlockNumber       int64                    `json:"maxBlockNumber"`
+	UnreachableAddresses map[string]client.MyTime `json:"unreachableAddresses"`
+}
+
+type apiWatchdog struct {
+	State      string          `json:"state"`
+	Severity   string          `json:"severity"`
+	Interval   int64           `json:"interval"`  //seconds
+	Threshold  int64           `json:"threshold"` //seconds
+	ReorgDepth int             `json:"reorgDepth"`
+	FullBlocks int             `json:"fullBlocks"`
+	MaxBaseFee float64         `json:"maxBaseFee"` //gwei
+	Recipients map[string]bool `json:"recipients"`
+}
+
+type apiWatchdogConfig struct {
+	Interval   *int64   `json:"interval"`
+	Threshold  *int64   `json:"threshold"`
+	ReorgDepth *int     `json:"reorgDepth"`
+	FullBlocks *int     `json:"fullBlocks"`
+	MaxBaseFee *float64 `json:"maxBaseFee"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeAPIError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, apiError{err.Error()})
+}
+
+//Writes the 405 and returns false if the method is not among the allowed
+func allowMethods(w http.ResponseWriter, r *http.Request, methods ...string) bool {
+	if methodAllowed(w, r, methods) {
+		return true
+	}
+	writeAPIError(w, http.StatusMethodNotAllowed, errors.New("method "+r.Method+" not allowed"))
+	return false
+}
+
+//Sets the Allow header if the method is not among the allowed
+func methodAllowed(w http.ResponseWriter, r *http.Request, methods []string) bool {
+	for _, m := range methods {
+		if r.Method == m {
+			return true
+		}
+	}
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	return false
+}
+
+func readJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		writeAPIError(w, http.StatusBadRequest, errors.New("invalid JSON body: "+err.Error()))
+		return false
+	}
+	return true
+}
+
+//Dispatches the path below /api/v1
+func (lhh *LilHttpHandler) handleAPI(w http.ResponseWriter, r *http.Request, path []string) {
+	if len(path) == 0 {
+		writeAPIError(w, http.StatusNotFound, errors.New("no resource"))
+		return
+	}
+	switch path[0] {
+	case "network":
+		lhh.apiNetwork(w, r, path[1:])
+	case "nodes":
+		lhh.apiNodes(w, r, path[1:])
+	case "watchdog":
+		lhh.apiWatchdog(w, r, path[1:])
+	case "password":
+		lhh.apiPassword(w, r, path[1:])
+	default:
+		writeAPIError(w, http.StatusNotFound, errors.New("unknown resource: "+path[0]))
+	}
+}
+
+func (lhh *LilHttpHandler) apiNetwork(w http.ResponseWriter, r *http.Request, path []string) {
+	if len(path) == 0 {
+		if allowMethods(w, r, http.MethodGet) {
+			writeJSON(w, http.StatusOK, lhh.networkSummary())
+		}
+		return
+	}
+	if len(path) > 1 {
+		writeAPIError(w, http.StatusNotFound, errors.New("unknown resource: "+strings.Join(path, "/")))
+		return
+	}
+	if path[0] == "discovery" {
+		if allowMethods(w, r, http.MethodGet) {
+			writeJSON(w, http.StatusOK, lhh.rpcClient.DiscoveryProgress())
+		}
+		return
+	}
+	var action func() error
+	switch path[0] {
+	case "discover":
+		action = lhh.rpcClient.DiscoverNetwork
+	case "rescan":
+		action = lhh.rpcClient.Rescan
+	case "heartbeat":
+		if allowMethods(w, r, http.MethodPost) {
+			progress, unreachable, stuck := lhh.rpcClient.HeartBeat()
+			writeJSON(w, http.StatusOK, map[string]interface{}{"time": client.MyTime(time.Now()), "progress": progress,
+				"unreachable": unreachable, "stuck": stuck})
+		}
+		return
+	case "bloop":
+		if allowMethods(w, r, http.MethodPost) {
+			blocks, err := lhh.rpcClient.Bloop()
+			if err != nil {
+				writeAPIError(w, http.StatusBadGateway, err)
+				return
+			}
+			writeJSON(w, http.StatusOK, blocks)
+		}
+		return
+	default:
+		writeAPIError(w, http.StatusNotFound, errors.New("unknown resource: network/"+path[0]))
+		return
+	}
+	if !allowMethods(w, r, http.MethodPost) {
+		return
+	}
+	if err := action(); err != nil {
+		writeAPIError(w, http.StatusBadGateway, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, lhh.networkSummary())
+}
+
+func (lhh *LilHttpHandler) networkSummary() apiNetwork {
+	bcn := lhh.rpcClient.NetModel()
+	sum := apiNetwork{NetworkID: bcn.NetworkID, AccessNode: bcn.AccessNodeID, Nodes: len(bcn.Nodes),
+		UnreachableAddresses: lhh.rpcClient.UnreachableAddresses()}
+	for _, n := range bcn.Nodes {
+		if !n.IsReachable() {
+			continue
+		}
+		sum.Reachable++
+		if n.IsStuck() {
+			sum.Stuck++
+		}
+		if s := n.LastBlockNumberSample; s != nil && int64(s.BlockNumber) > sum.MaxBlockNumber {
+			sum.MaxBlockNumber = int64(s.BlockNumber)
+		}
+	}
+	return sum
+}
+
+func (lhh *LilHttpHandler) apiNodes(w http.ResponseWriter, r *http.Request, path []string) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+	bcn := lhh.rpcClient.NetModel()
+	if len(path) == 0 {
+		nodes := []apiNode{}
+		for _, n := range bcn.Nodes {
+			nodes = append(nodes, newAPINode(n))
+		}
+		sort.Slice(nodes, func(i, j int) bool { return nodes[i].ID < nodes[j].ID })
+		writeJSON(w, http.StatusOK, nodes)
+		return
+	}
+	n, ok := bcn.FindNode(path[0])
+	if !ok {
+		writeAPIError(w, http.StatusNotFound, errors.New("no such node: "+path[0]))
+		return
+	}
+	switch {
+	case len(path) == 1:
+		writeJSON(w, http.StatusOK, newAPINode(n))
+	case len(path) == 2 && path[1] == "peers":
+		peers := []apiPeer{}
+		for id, p := range n.Peers {
+			ap := apiPeer{ID: id, Name: p.ShortName}
+			ap.SeenAs, _ = n.PeerSeenAs(id)
+			if mn, known := bcn.Nodes[id]; known {
+				ap.Known = true
+				ap.Name = mn.ShortName
+			}
+			peers = append(peers, ap)
+		}
+		sort.Slice(peers, func(i, j int) bool { return peers[i].ID < peers[j].ID })
+		writeJSON(w, http.StatusOK, peers)
+	default:
+		writeAPIError(w, http.StatusNotFound, errors.New("unknown resource: nodes/"+strings.Join(path, "/")))
+	}
+}
+
+func newAPINode(n *client.Node) apiNode {
+	an := apiNode{ID: n.ID, Name: n.ShortName, FullName: n.FullName, ClientType: n.ClientType(), ClientVersion: n.ClientVersion,
+		Enode: n.Enode, RPCAddress: n.RPCAddress, Addresses: []string{}, Reachable: n.IsReachable(), Stuck: n.IsStuck(),
+		LastReach: n.LastReach, Syncing: n.SyncStatus, Consensus: n.Consensus(), Peers: []client.NodeID{}}
+	for a, ok := range n.KnownAddresses {
+		if ok {
+			an.Addresses = append(an.Addresses, a)
+		}
+	}
+	sort.Strings(an.Addresses)
+	if s := n.LastBlockNumberSample; s != nil {
+		bn := int64(s.BlockNumber)
+		an.BlockNumber = &bn
+		an.BlockSampled = s.Sampled
+	}
+	if s := n.TxpoolStatus; s != nil {
+		pending, queued := int64(s.Pending), int64(s.Queued)
+		an.Pending, an.Queued = &pending, &queued
+	}
+	for id := range n.Peers {
+		an.Peers = append(an.Peers, id)
+	}
+	sort.Slice(an.Peers, func(i, j int) bool { return an.Peers[i] < an.Peers[j] })
+	return an
+}
+
+func (lhh *LilHttpHandler) apiWatchdog(w http.ResponseWriter, r *http.Request, path []string) {
+	if lhh.watchdog == nil {
+		writeAPIError(w, http.StatusNotFound, errors.New("the watchdog has not been started"))
+		return
+	}
+	switch {
+	case len(path) == 0:
+		if allowMethods(w, r, http.MethodGet) {
+			writeJSON(w, http.StatusOK, lhh.watchdogStatus())
+		}
+	case len(path) == 1 && path[0] == "config":
+		if !allowMethods(w, r, http.MethodPut, http.MethodPatch) {
+			return
+		}
+		var c apiWatchdogConfig
+		if !readJSON(w, r, &c) {
+			return
+		}
+		if (c.Interval != nil && *c.Interval <= 0) || (c.Threshold != nil && *c.Threshold <= 0) || (c.ReorgDepth != nil && *c.ReorgDepth < 0) ||
+			(c.FullBlocks != nil && *c.FullBlocks < 0) || (c.MaxBaseFee != nil && *c.MaxBaseFee < 0) {
+			writeAPIError(w, http.StatusBadRequest, errors.New("the interval and the threshold have to be positive, the reorg depth and the fee rules not negative"))
+			return
+		}
+		if c.Interval != nil {
+			lhh.watchdog.SetInterval(*c.Interval)
+		}
+		if c.Threshold != nil {
+			lhh.watchdog.SetThreshold(*c.Threshold)
+		}
+		if c.ReorgDepth != nil {
+			lhh.watchdog.SetReorgDepth(*c.ReorgDepth)
+		}
+		if c.FullBlocks != nil {
+			lhh.watchdog.SetFullBlocks(*c.FullBlocks)
+		}
+		if c.MaxBaseFee != nil {
+			lhh.watchdog.SetMaxBaseFee(*c.MaxBaseFee)
+		}
+		writeJSON(w, http.StatusOK, lhh.watchdogStatus())
+	case len(path) == 1 && path[0] == "state":
+		if !allowMethods(w, r, http.MethodPut) {
+			return
+		}
+		var s struct {
+			State string `json:"state"`
+		}
+		if !readJSON(w, r, &s) {
+			return
+		}
+		if s.State != "OK" {
+			writeAPIError(w, http.StatusBadRequest, errors.New("the state can only be set to OK"))
+			return
+		}
+		lhh.watchdog.SetStatusOk()
+		writeJSON(w, http.StatusOK, lhh.watchdogStatus())
+	case len(path) >= 1 && path[0] == "recipients":
+		lhh.apiRecipients(w, r, path[1:])
+	default:
+		writeAPIError(w, http.StatusNotFound, errors.New("unknown resource: watchdog/"+strings.Join(path, "/")))
+	}
+}
+
+func (lhh *LilHttpHandler) watchdogStatus() apiWatchdog {
+	st := lhh.watchdog.GetStatus()
+	return apiWatchdog{State: st.Main(), Severity: st.Severity(), Interval: lhh.watchdog.GetInterval(),
+		Threshold: lhh.watchdog.GetThreshold(), ReorgDepth: lhh.watchdog.GetReorgDepth(),
+		FullBlocks: lhh.watchdog.GetFullBlocks(), MaxBaseFee: lhh.watchdog.GetMaxBaseFee(), Recipients: lhh.watchdog.GetRecipients()}
+}
+
+func (lhh *LilHttpHandler) apiRecipients(w http.ResponseWriter, r *http.Request, path []string) {
+	switch {
+	case len(path) == 0:
+		if !allowMethods(w, r, http.MethodGet, http.MethodPost) {
+			return
+		}
+		if r.Method == http.MethodPost {
+			var rc struct {
+				Email string `json:"email"`
+			}
+			if !readJSON(w, r, &rc) {
+				return
+			}
+			if !lhh.watchdog.AddRecipient(rc.Email) {
+				writeAPIError(w, http.StatusBadRequest, errors.New("invalid email: "+rc.Email))
+				return
+			}
+			writeJSON(w, http.StatusCreated, lhh.watchdog.GetRecipients())
+			return
+		}
+		writeJSON(w, http.StatusOK, lhh.watchdog.GetRecipients())
+	case len(path) == 1:
+		if !allowMethods(w, r, http.MethodDelete) {
+			return
+		}
+		if !lhh.watchdog.RemoveRecipient(path[0]) {
+			writeAPIError(w, http.StatusNotFound, errors.New("no such recipient: "+path[0]))
+			return
+		}
+		writeJSON(w, http.StatusOK, lhh.watchdog.GetRecipients())
+	case len(path) == 2 && path[1] == "block":
+		if !allowMethods(w, r, http.MethodPost) {
+			return
+		}
+		if !lhh.watchdog.BlockRecipient(path[0]) {
+			writeAPIError(w, http.StatusNotFound, errors.New("no such recipient: "+path[0]))
+			return
+		}
+		writeJSON(w, http.StatusOK, lhh.watchdog.GetRecipients())
+	default:
+		writeAPIError(w, http.StatusNotFound, errors.New("unknown resource: watchdog/recipients/"+strings.Join(path, "/")))
+	}
+}
+
+func (lhh *LilHttpHandler) apiPassword(w http.ResponseWriter, r *http.Request, path []string) {
+	if len(path) > 0 {
+		writeAPIError(w, http.StatusNotFound, errors.New("unknown resource: password/"+strings.Join(path, "/")))
+		return
+	}
+	if !allowMethods(w, r, http.MethodPut) {
+		return
+	}
+	username, _, ok := r.BasicAuth()
+	if !lhh.config.BasicAuth || !ok {
+		writeAPIError(w, http.StatusConflict, errors.New("the password can only be changed with the Basic Authentication on"))
+		return
+	}
+	var p struct {
+		Password string `json:"password"`
+	}
+	if !readJSON(w, r, &p) {
+		return
+	}
+	if len(p.Password) == 0 {
+		writeAPIError(w, http.StatusBadRequest, errors.New("empty password"))
+		return
+	}
+	lhh.setPassword(username, p.Password)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -5,9 +5,11 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"io/ioutil"
 	"log"
 	"net/http"
+	"strings"
 	"sync"
 )
 
@@ -18,18 +20,25 @@ func (lhh *LilHttpHandler) BasicAuthHandler(w http.ResponseWriter, r *http.Reque
 		lhh.Handler(w, r)
 		return
 	}
+	if strings.HasPrefix(r.URL.Path, "/"+apiPrefix+"/") {
+		writeAPIError(w, http.StatusUnauthorized, errors.New("Not authorized"))
+		return
+	}
 	http.Error(w, "Not authorized", 401)
 }
 
 func validateAuth(username, password string) bool {
 	arrSha := sha256.Sum224([]byte(password))
 	orig := hex.EncodeToString(arrSha[:])
+	passMx.RLock()
 	v, has := passMap[username]
+	passMx.RUnlock()
 	return has && orig == v
 
 }
 
 var passMap map[string]string
+var passMx sync.RWMutex
 
 //Try to read username/password pairs from a file.
 //If it does not work, sets the defult pair of sanlab:sanlab2018
@@ -55,7 +64,9 @@ func save(ctx context.Context) {
 	select {
 	case <-ctx.Done():
 
+		passMx.RLock()
 		bytes, err := json.Marshal(passMap)
+		passMx.RUnlock()
 		if err != nil {
 			log.Println(err)
 			return
@@ -67,5 +78,7 @@ func save(ctx context.Context) {
 
 func (lhh *LilHttpHandler) setPassword(user, passwd string) {
 	ar := sha256.Sum224([]byte(passwd))
+	passMx.Lock()
+	defer passMx.Unlock()
 	passMap[user] = hex.EncodeToString(ar[:])
 }
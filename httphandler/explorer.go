@@ -0,0 +1,96 @@
+package httphandler
+
+import (
+	"github.com/san-lab/toolsmith/client"
+	"github.com/san-lab/toolsmith/templates"
+	"net/http"
+	"net/url"
+)
+
+//The block explorer pages. The node to query comes in the "node" param (an ID, address or name), the access node if none
+
+//Reads what the explorer page shows. The page comes back even with an error, to keep the chosen node
+func (lhh *LilHttpHandler) explorerPage(comm string, r *http.Request) (*client.ExplorerPage, error) {
+	page := &client.ExplorerPage{}
+	node, err := lhh.rpcClient.ExplorerNode(r.FormValue(nodeparamname))
+	if err != nil {
+		return page, err
+	}
+	page.Node = node
+	switch comm {
+	case explorer:
+		page.Blocks, err = lhh.rpcClient.LatestBlocks(node)
+	case block:
+		page.Block, err = lhh.rpcClient.GetBlock(node, r.FormValue(blockparamname))
+		if err == nil {
+			page.Head = -1
+			if node.LastBlockNumberSample != nil {
+				page.Head = node.LastBlockNumberSample.BlockNumber
+			}
+			if head, herr := lhh.rpcClient.BlockNumber(node); herr == nil {
+				page.Head = head
+			}
+		}
+	case tx:
+		page.Tx, page.Receipt, err = lhh.rpcClient.GetTransaction(node, r.FormValue(hashparamname))
+	case receipt:
+		page.Receipt, err = lhh.rpcClient.GetReceipt(node, r.FormValue(hashparamname))
+	case account:
+		page.Account, err = lhh.rpcClient.GetAccount(node, r.FormValue(validatorparamname))
+	}
+	return page, err
+}
+
+//Sends the browser to the page of what has been searched for. Returns the error if there is no such page
+func (lhh *LilHttpHandler) explorerSearch(w http.ResponseWriter, r *http.Request) error {
+	node, err := lhh.rpcClient.ExplorerNode(r.FormValue(nodeparamname))
+	if err != nil {
+		return err
+	}
+	kind, key, err := lhh.rpcClient.Search(node, r.FormValue(queryparamname))
+	if err != nil {
+		return err
+	}
+	q := url.Values{nodeparamname: {string(node.ID)}}
+	switch kind {
+	case client.SearchBlock:
+		q.Set(blockparamname, key)
+	case client.SearchTx:
+		q.Set(hashparamname, key)
+	case client.SearchAccount:
+		q.Set(validatorparamname, key)
+	}
+	http.Redirect(w, r, "/"+kind+"?"+q.Encode(), http.StatusFound)
+	return nil
+}
+
+//The explorer page of a block, transaction or receipt call, the raw one if there is nothing to show
+func explorerResult(bcn *client.BlockchainNet, eNode string, callData *client.CallData) (string, interface{}) {
+	if !callData.Parsed {
+		return templates.Raw, callData
+	}
+	page := &client.ExplorerPage{}
+	page.Node, _ = bcn.FindNode(eNode)
+	switch res := callData.ParsedResult.(type) {
+	case *client.Block:
+		if len(res.Hash) > 0 {
+			page.Block = res
+			page.Head = -1
+			if page.Node != nil && page.Node.LastBlockNumberSample != nil {
+				page.Head = page.Node.LastBlockNumberSample.BlockNumber
+			}
+			return templates.Block, page
+		}
+	case *client.Transaction:
+		if len(res.Hash) > 0 {
+			page.Tx = res
+			return templates.Tx, page
+		}
+	case *client.Receipt:
+		if len(res.TransactionHash) > 0 {
+			page.Receipt = res
+			return templates.Receipt, page
+		}
+	}
+	return templates.Raw, callData
+}
@@ -5,11 +5,14 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/san-lab/toolsmith/abi"
 	"github.com/san-lab/toolsmith/client"
+	"github.com/san-lab/toolsmith/store"
 	"github.com/san-lab/toolsmith/templates"
 	"github.com/san-lab/toolsmith/watchdog"
 	"log"
 	"net/http"
+	"os"
 	"regexp"
 	"sort"
 	"strconv"
@@ -43,6 +46,64 @@ const interval = "interval" //param name
 const setpassword = "setpassword"
 const setthreshold = "setthreshold"
 const threshold = "threshold" // param name
+const discoveryprogress = "discoveryprogress"
+const loadrpcconfig = "loadrpcconfig"
+const clique = "clique"
+const validators = "validators"
+const bftvote = "bftvote"
+const bftdiscard = "bftdiscard"
+const cliquevotes = "cliquevotes"
+const cliquepropose = "cliquepropose"
+const cliquediscard = "cliquediscard"
+const forks = "forks"
+const reorgs = "reorgs"
+const partitions = "partitions"
+const setreorgdepth = "setreorgdepth"
+const charts = "charts"
+const history = "history"
+const compactstore = "compactstore"
+const explorer = "explorer"
+const block = "block"
+const tx = "tx"
+const receipt = "receipt"
+const account = "account"
+const search = "search"
+const abiregistry = "abi"
+const abiadd = "abiadd"
+const abiremove = "abiremove"
+const contract = "contract"
+const abicall = "abicall"
+const txpool = "txpool"
+const propagation = "propagation"
+const replace = "replace"
+const replacesend = "replacesend"
+const replacement = "replacement"
+const fees = "fees"
+const setfullblocks = "setfullblocks"
+const setmaxbasefee = "setmaxbasefee"
+const metric = "metric"                    //param name
+const depth = "depth"                      //param name
+const blocks = "blocks"                    //param name
+const gwei = "gwei"                        //param name
+const nodeparamname = "node"               //param name
+const validatorparamname = "addr"          //param name
+const authparamname = "auth"               //param name
+const blockparamname = "ref"               //param name
+const hashparamname = "hash"               //param name
+const queryparamname = "q"                 //param name
+const nameparamname = "name"               //param name
+const abiparamname = "abi"                 //param name
+const fnparamname = "fn"                   //param name
+const fromparamname = "from"               //param name
+const valueparamname = "value"             //param name
+const sendparamname = "send"               //param name
+const cancelparamname = "cancel"           //param name
+const viaparamname = "via"                 //param name
+const passwordparamname = "password"       //param name
+const gaspriceparamname = "gasPrice"       //param name
+const maxfeeparamname = "maxFee"           //param name
+const tipparamname = "tip"                 //param name
+const replacementparamname = "replacement" //param name
 
 const passwdFile = "http.passwd.json"
 
@@ -62,6 +123,36 @@ func NewHttpHandler(c Config, ctx context.Context) (lhh *LilHttpHandler, err err
 	lhh.config = c
 	lhh.renderer = templates.NewRenderer()
 	lhh.rpcClient, err = client.NewClient(c.RPCFirstEntry, c.MockMode, c.DumpRPC)
+	if err == nil && c.DiscoveryWorkers > 0 {
+		lhh.rpcClient.Workers = c.DiscoveryWorkers
+	}
+	if err == nil && len(c.EndpointsFile) > 0 {
+		//Most networks need no endpoint configuration, so the default file may well be missing
+		if lerr := lhh.rpcClient.LoadEndpointConfigs(c.EndpointsFile); lerr != nil && (c.EndpointsFileSet || !os.IsNotExist(lerr)) {
+			log.Println(lerr)
+		}
+	}
+	if err == nil && len(c.WSPort) > 0 {
+		lhh.rpcClient.DefaultWSPort = c.WSPort
+		lhh.rpcClient.SubscribePendingTxs = c.WSPendingTxs
+		lhh.rpcClient.StartSubscriptions(ctx)
+	}
+	if err == nil && len(c.StoreDir) > 0 {
+		st, serr := store.Open(c.StoreDir)
+		if serr != nil {
+			log.Println(serr)
+		} else {
+			st.Start(ctx)
+			lhh.rpcClient.SetStore(st)
+		}
+	}
+	if err == nil && len(c.ABIFile) > 0 {
+		abis, aerr := abi.OpenRegistry(c.ABIFile)
+		if aerr != nil {
+			log.Println(aerr)
+		}
+		lhh.rpcClient.SetABIRegistry(abis)
+	}
 	if c.StartWatchdog {
 		lhh.watchdog = watchdog.StartWatchdog(lhh.rpcClient, ctx)
 	}
@@ -74,20 +165,35 @@ func NewHttpHandler(c Config, ctx context.Context) (lhh *LilHttpHandler, err err
 // Handles incoming requests. Some will be forwarded to the RPC client.
 // Assumes the request path has either: 1 part - interpreted as a /command with logic implemented within the client
 //                                  or: 2 parts - interpreted as /node/ethMethod
+//                                  or: /ipc:/path/to/geth.ipc/ethMethod
 // The port No set at Client initialization is used for the RPC call
 func (lhh *LilHttpHandler) Handler(w http.ResponseWriter, r *http.Request) {
 	// FormValue() does the call to Parse()
 	if r.FormValue(toggle) == "yes" {
-		lhh.rpcClient.LocalInfo.RawMode = !lhh.rpcClient.LocalInfo.RawMode
+		lhh.rpcClient.ToggleRawMode()
 	}
 	isSlash := func(c rune) bool { return c == '/' }
 	f := strings.FieldsFunc(r.URL.Path, isSlash)
 	//log.Println(f)
+	//An IPC node: /ipc:/path/to/geth.ipc/ethMethod
+	if len(f) > 2 && f[0] == "ipc:" {
+		f = []string{"ipc:///" + strings.Join(f[1:len(f)-1], "/"), f[len(f)-1]}
+	}
+	if len(f) > 0 && f[0] == apiPrefix {
+		if len(f) < 2 || f[1] != apiVersion {
+			writeAPIError(w, http.StatusNotFound, errors.New("unknown api version"))
+			return
+		}
+		lhh.handleAPI(w, r, f[2:])
+		return
+	}
 	switch len(f) {
 	case 1:
 		comm := f[0]
 		if client.CamelCaseKnownCommand(&comm) {
 			lhh.RpcCallAndRespond(w, r, lhh.config.RPCFirstEntry, comm)
+		} else if comm == metrics {
+			lhh.serveMetrics(w)
 		} else if strings.HasPrefix(comm, "json") {
 			lhh.handleJSON(w, r, comm)
 		} else {
@@ -98,8 +204,8 @@ func (lhh *LilHttpHandler) Handler(w http.ResponseWriter, r *http.Request) {
 		eMethod := f[1]
 		lhh.RpcCallAndRespond(w, r, eNode, eMethod)
 	default:
-		cc := lhh.rpcClient.LocalInfo
-		if lhh.rpcClient.NetModel.NetworkID == "" {
+		cc := lhh.rpcClient.LocalInfo()
+		if lhh.rpcClient.NetModel().NetworkID == "" {
 			lhh.rpcClient.DiscoverNetwork()
 		}
 		rdata := templates.RenderData{TemplateName: "magic", HeaderData: &cc, Client: lhh.rpcClient}
@@ -121,15 +227,18 @@ func (lhh *LilHttpHandler) GetHandler(withAuth bool) handler {
 
 func (lhh *LilHttpHandler) SpecialCommand(w http.ResponseWriter, r *http.Request, comm string) {
 	var err error
-	cc := lhh.rpcClient.LocalInfo
+	cc := lhh.rpcClient.LocalInfo()
 	cc.Watchdog = lhh.watchdog != nil
 	if cc.Watchdog {
 		cc.WatchdogInterval = lhh.watchdog.GetInterval()
 	}
 	rdata := templates.RenderData{HeaderData: &cc, TemplateName: templates.Home, Client: lhh.rpcClient}
+	if !allowCommandMethods(w, r, comm) {
+		return
+	}
 	switch comm {
 	case peers:
-		node, ok := lhh.rpcClient.NetModel.Nodes[client.NodeID(r.FormValue("nodeid"))]
+		node, ok := lhh.rpcClient.NetModel().Nodes[client.NodeID(r.FormValue("nodeid"))]
 		if ok {
 			rdata.BodyData = node
 			rdata.TemplateName = templates.Peers
@@ -137,11 +246,11 @@ func (lhh *LilHttpHandler) SpecialCommand(w http.ResponseWriter, r *http.Request
 	case discover:
 		err = lhh.rpcClient.DiscoverNetwork()
 		rdata.TemplateName = templates.Network
-		rdata.BodyData = lhh.rpcClient.NetModel
+		rdata.BodyData = lhh.rpcClient.NetModel()
 	case rescan:
 		err = lhh.rpcClient.Rescan()
 		rdata.TemplateName = templates.Network
-		rdata.BodyData = lhh.rpcClient.NetModel
+		rdata.BodyData = lhh.rpcClient.NetModel()
 	case bloop:
 		m, _ := lhh.rpcClient.Bloop()
 		rdata.TemplateName = templates.ListMap
@@ -152,16 +261,144 @@ func (lhh *LilHttpHandler) SpecialCommand(w http.ResponseWriter, r *http.Request
 		fmt.Fprintf(w, "%s>  \n progress: %v, \n unreachable %v, \n stuck %v", client.MyTime(time.Now()), ok, nodesu, nodess)
 		return
 		//rdata.Error = fmt.Sprintf("Heartbeat: %s for the %v nodes reachable", ok, nodes) //A hack!
+	case discoveryprogress:
+		p := lhh.rpcClient.DiscoveryProgress()
+		rdata.TemplateName = templates.ListMap
+		rdata.BodyData = map[string]interface{}{"running": p.Running, "started": p.Started, "finished": p.Finished,
+			"found": p.Found, "probed": p.Probed, "failed": p.Failed, "workers": lhh.rpcClient.Workers}
+		if p.Running {
+			rdata.HeaderData.SetRefresh(2)
+		}
+	case clique:
+		rdata.TemplateName = templates.Clique
+		rdata.BodyData = lhh.rpcClient.NetModel().CliqueReport()
+	case forks:
+		rdata.TemplateName = templates.Forks
+		rdata.BodyData = lhh.rpcClient.DetectForks()
+	case partitions:
+		rdata.TemplateName = templates.Partitions
+		rdata.BodyData = lhh.rpcClient.NetModel().AnalyzeGraph()
+	case charts:
+		rdata.TemplateName = templates.Charts
+		rdata.BodyData = r.FormValue(metric)
+		if len(r.FormValue(metric)) == 0 {
+			rdata.BodyData = client.MetricBlockNumber
+		}
+	case compactstore:
+		if st := lhh.rpcClient.Store(); st != nil {
+			err = st.Compact()
+		}
+		fallthrough
+	case history:
+		rdata.TemplateName = templates.History
+		if st := lhh.rpcClient.Store(); st != nil {
+			rdata.BodyData = struct {
+				Incidents   []watchdog.Incident
+				PeerChanges []client.PeerChange
+				Streams     []store.StreamStats
+				Config      store.Config
+			}{watchdog.Incidents(st), lhh.rpcClient.PeerChanges(), st.Stats(), st.GetConfig()}
+		}
+	case explorer, block, tx, receipt, account:
+		rdata.TemplateName = comm
+		rdata.BodyData, err = lhh.explorerPage(comm, r)
+	case search:
+		if err = lhh.explorerSearch(w, r); err == nil {
+			return
+		}
+		rdata.TemplateName = templates.Explorer
+		rdata.BodyData, _ = lhh.explorerPage(explorer, r)
+	case txpool:
+		rdata.TemplateName = templates.Txpool
+		var node *client.Node
+		if node, err = lhh.rpcClient.ExplorerNode(r.FormValue(nodeparamname)); err == nil {
+			rdata.BodyData, err = lhh.rpcClient.TxpoolReport(node)
+		}
+	case propagation:
+		rdata.TemplateName = templates.Propagation
+		after := client.DefaultPropagationThreshold
+		if len(r.FormValue(threshold)) > 0 {
+			if after, err = time.ParseDuration(r.FormValue(threshold)); err != nil {
+				break
+			}
+		}
+		rdata.BodyData = lhh.rpcClient.ComparePools(after)
+	case fees:
+		rdata.TemplateName = templates.Fees
+		var node *client.Node
+		if node, err = lhh.rpcClient.ExplorerNode(r.FormValue(nodeparamname)); err == nil {
+			rdata.BodyData, err = lhh.rpcClient.TrackFees(node)
+		}
+	case replace:
+		rdata.TemplateName = templates.Replace
+		rdata.BodyData, err = lhh.replacePage(r)
+	case replacesend:
+		if err = lhh.replaceSend(w, r); err == nil {
+			return
+		}
+		rdata.TemplateName = templates.Replace
+		rdata.BodyData, _ = lhh.replacePage(r)
+	case replacement:
+		rdata.TemplateName = templates.Replacement
+		var node *client.Node
+		if node, err = lhh.rpcClient.ExplorerNode(r.FormValue(nodeparamname)); err == nil {
+			var st *client.ReplacementStatus
+			st, err = lhh.rpcClient.FollowReplacement(node, r.FormValue(hashparamname), r.FormValue(replacementparamname))
+			rdata.BodyData = st
+			if err == nil && !st.IsMined() {
+				rdata.HeaderData.SetRefresh(5)
+			}
+		}
+	case abiadd:
+		err = lhh.rpcClient.ABIs().Add(r.FormValue(validatorparamname), r.FormValue(nameparamname), []byte(r.FormValue(abiparamname)))
+		rdata.TemplateName = templates.ABIRegistry
+	case abiremove:
+		err = lhh.rpcClient.ABIs().Remove(r.FormValue(validatorparamname))
+		fallthrough
+	case abiregistry:
+		rdata.TemplateName = templates.ABIRegistry
+	case contract, abicall:
+		rdata.TemplateName = templates.Contract
+		rdata.BodyData, err = lhh.contractPage(comm, r)
+	case reorgs:
+		rdata.TemplateName = templates.Reorgs
+		rdata.BodyData = lhh.rpcClient.NetModel().Reorgs()
+	case cliquepropose:
+		ids := formNodeIDs(r)
+		err = lhh.rpcClient.ProposeCliqueVote(ids, r.FormValue(validatorparamname), r.FormValue(authparamname) != "false")
+		rdata.TemplateName = templates.CliqueVotes
+		rdata.BodyData = lhh.rpcClient.CliqueVoting()
+	case cliquediscard:
+		ids := formNodeIDs(r)
+		err = lhh.rpcClient.DiscardCliqueVote(ids, r.FormValue(validatorparamname))
+		fallthrough
+	case cliquevotes:
+		rdata.TemplateName = templates.CliqueVotes
+		rdata.BodyData = lhh.rpcClient.CliqueVoting()
+	case bftvote:
+		id := client.NodeID(r.FormValue(nodeparamname))
+		err = lhh.rpcClient.ProposeValidatorVote(id, r.FormValue(validatorparamname), r.FormValue(authparamname) != "false")
+		fallthrough
+	case validators:
+		rdata.TemplateName = templates.Validators
+		rdata.BodyData = lhh.rpcClient.NetModel().BFTReport()
+	case bftdiscard:
+		id := client.NodeID(r.FormValue(nodeparamname))
+		err = lhh.rpcClient.DiscardValidatorVote(id, r.FormValue(validatorparamname))
+		rdata.TemplateName = templates.Validators
+		rdata.BodyData = lhh.rpcClient.NetModel().BFTReport()
 	case debugOff:
-		lhh.rpcClient.DebugMode = false
+		lhh.rpcClient.SetDebugMode(false)
 	case debugOn:
-		lhh.rpcClient.DebugMode = true
+		lhh.rpcClient.SetDebugMode(true)
 	case magic:
 		rdata.TemplateName = "magic"
 		lhh.rpcClient.Rescan()
-		rdata.BodyData = &lhh.rpcClient.NetModel
+		rdata.BodyData = lhh.rpcClient.NetModel()
 	case loadtemplates:
 		lhh.renderer.LoadTemplates()
+	case loadrpcconfig:
+		err = lhh.rpcClient.LoadEndpointConfigs(lhh.config.EndpointsFile)
 	case rawnodes:
 		rdata.TemplateName = "nodelist"
 		lhh.rpcClient.Rescan()
@@ -198,6 +435,21 @@ func (lhh *LilHttpHandler) SpecialCommand(w http.ResponseWriter, r *http.Request
 		if err == nil {
 			lhh.watchdog.SetThreshold(i)
 		}
+	case setreorgdepth:
+		i, err := strconv.ParseInt(r.Form.Get(depth), 0, 0)
+		if err == nil {
+			lhh.watchdog.SetReorgDepth(int(i))
+		}
+	case setfullblocks:
+		i, err := strconv.ParseInt(r.Form.Get(blocks), 0, 0)
+		if err == nil {
+			lhh.watchdog.SetFullBlocks(int(i))
+		}
+	case setmaxbasefee:
+		f, err := strconv.ParseFloat(r.Form.Get(gwei), 64)
+		if err == nil {
+			lhh.watchdog.SetMaxBaseFee(f)
+		}
 	case setwatchdogstatusok:
 		lhh.watchdog.SetStatusOk()
 		fallthrough
@@ -226,10 +478,41 @@ func (lhh *LilHttpHandler) SpecialCommand(w http.ResponseWriter, r *http.Request
 
 }
 
+//The commands changing the state of the network or of the tool, and the methods they are accepted by,
+//so that no link, prefetch or cross-site GET can cast a vote, send a transaction or edit the registry
+var commandMethods = map[string][]string{
+	cliquepropose: {http.MethodPost},
+	cliquediscard: {http.MethodPost},
+	bftvote:       {http.MethodPost},
+	bftdiscard:    {http.MethodPost},
+	replacesend:   {http.MethodPost},
+	abiadd:        {http.MethodPost},
+	abiremove:     {http.MethodPost},
+	abicall:       {http.MethodPost}, //may send a transaction
+}
+
+//The allowMethods of the api for the commands of the html frontend, answering the browser in plain text
+func allowCommandMethods(w http.ResponseWriter, r *http.Request, comm string) bool {
+	methods, ok := commandMethods[comm]
+	if !ok || methodAllowed(w, r, methods) {
+		return true
+	}
+	http.Error(w, "method "+r.Method+" not allowed", http.StatusMethodNotAllowed)
+	return false
+}
+
+//The "node" values of the form, there may be several
+func formNodeIDs(r *http.Request) (ids []client.NodeID) {
+	for _, id := range r.Form[nodeparamname] {
+		ids = append(ids, client.NodeID(id))
+	}
+	return
+}
+
 func (lhh *LilHttpHandler) handleJSON(writer http.ResponseWriter, rq *http.Request, comm string) {
 	writer.Header().Set("Content-Type", "application/json")
 	writer.WriteHeader(200)
-	nodes := lhh.rpcClient.NetModel.GetJsonNodes()
+	nodes := lhh.rpcClient.NetModel().GetJsonNodes()
 	json.NewEncoder(writer).Encode(nodes)
 
 }
@@ -237,7 +520,7 @@ func (lhh *LilHttpHandler) handleJSON(writer http.ResponseWriter, rq *http.Reque
 // Ethereum RPC call to the eNode and rendering the appropriate HTML result page.
 // Parameter values from the url query will be marshaled as json params[], if their keys are of the form "parX", where X=0..9
 // but if there are multiple values for any particular key, only the first value will be used.
-// The parameter names will be skipped.
+// The parameter names will be skipped. The values which are JSON objects or arrays, or true/false, are passed as such.
 func (lhh *LilHttpHandler) RpcCallAndRespond(w http.ResponseWriter, r *http.Request, eNode string, eMethod string) {
 	client.CamelCaseKnownCommand(&eMethod) //We could stop here if false, but what if there are new methods?
 	var err error
@@ -258,7 +541,7 @@ func (lhh *LilHttpHandler) RpcCallAndRespond(w http.ResponseWriter, r *http.Requ
 	if len(keys) > 0 {
 		sort.Strings(keys)
 		for _, pk := range keys {
-			callData.Command.Params = append(callData.Command.Params, r.Form[pk][0])
+			callData.Command.Params = append(callData.Command.Params, passthroughParam(r.Form[pk][0]))
 		}
 	}
 	// End of param handling
@@ -274,8 +557,8 @@ func (lhh *LilHttpHandler) RpcCallAndRespond(w http.ResponseWriter, r *http.Requ
 		fmt.Fprintln(w, err)
 		return
 	}
-	cc := lhh.rpcClient.LocalInfo //Cloning, i hope
-	rdata := templates.RenderData{HeaderData: &cc, BodyData: callData}
+	cc := lhh.rpcClient.LocalInfo() //Cloning, i hope
+	rdata := templates.RenderData{HeaderData: &cc, BodyData: callData, Client: lhh.rpcClient}
 	if showRaw {
 		rdata.TemplateName = templates.Raw
 	} else {
@@ -288,6 +571,16 @@ func (lhh *LilHttpHandler) RpcCallAndRespond(w http.ResponseWriter, r *http.Requ
 			rdata.TemplateName = templates.TxpoolStatus
 		case "admin_datadir", "net_version":
 			rdata.TemplateName = templates.BlockNumber
+		case "eth_getBlockByNumber", "eth_getBlockByHash", "eth_getTransactionByHash", "eth_getTransactionReceipt":
+			rdata.TemplateName, rdata.BodyData = explorerResult(lhh.rpcClient.NetModel(), eNode, callData)
+		case "eth_call", "eth_getLogs":
+			rdata.TemplateName, rdata.BodyData = decodedCallResult(lhh.rpcClient.ABIs(), callData)
+		case "txpool_content":
+			var terr error
+			rdata.TemplateName, rdata.BodyData, terr = lhh.txpoolResult(eNode, callData)
+			if terr != nil {
+				rdata.Error = terr.Error()
+			}
 		default:
 			rdata.TemplateName = templates.Raw
 
@@ -299,9 +592,16 @@ func (lhh *LilHttpHandler) RpcCallAndRespond(w http.ResponseWriter, r *http.Requ
 }
 
 type Config struct {
-	RPCFirstEntry string
-	MockMode      bool
-	DumpRPC       bool
-	StartWatchdog bool
-	BasicAuth     bool
+	RPCFirstEntry    string
+	MockMode         bool
+	DumpRPC          bool
+	StartWatchdog    bool
+	BasicAuth        bool
+	DiscoveryWorkers int
+	WSPort           string
+	WSPendingTxs     bool
+	EndpointsFile    string
+	EndpointsFileSet bool   //the EndpointsFile is given, not the default one, so it has to be there
+	StoreDir         string //where the samples and the events are kept over restarts, none if empty
+	ABIFile          string //where the contract ABIs are kept, none if empty
 }
@@ -0,0 +1,163 @@
+package httphandler
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/san-lab/toolsmith/client"
+	"github.com/san-lab/toolsmith/watchdog"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//The Prometheus text exposition of the network model, the RPC call counters and the watchdog state.
+//Computed from the latest published model, so a scrape does not probe the nodes; the watchdog or the rescans keep it fresh
+
+const metrics = "metrics"
+
+type sample struct {
+	labels string
+	value  float64
+}
+
+//Writes a metric family with its HELP and TYPE lines. Nothing is written for no samples
+func writeFamily(buf *bytes.Buffer, name, typ, help string, samples []sample) {
+	if len(samples) == 0 {
+		return
+	}
+	fmt.Fprintf(buf, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+	writeSamples(buf, name, samples)
+}
+
+func writeSamples(buf *bytes.Buffer, name string, samples []sample) {
+	for _, s := range samples {
+		fmt.Fprintf(buf, "%s%s %v\n", name, s.labels, s.value)
+	}
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+//Label pairs, as name, value, name, value...
+func labels(pairs ...string) string {
+	var parts []string
+	for i := 0; i+1 < len(pairs); i += 2 {
+		parts = append(parts, pairs[i]+`="`+labelEscaper.Replace(pairs[i+1])+`"`)
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func (lhh *LilHttpHandler) serveMetrics(w http.ResponseWriter) {
+	bcn := lhh.rpcClient.NetModel()
+	var nodes []*client.Node
+	for _, n := range bcn.Nodes {
+		nodes = append(nodes, n)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].ID < nodes[j].ID })
+
+	var maxHeight float64
+	var reachable int
+	for _, n := range nodes {
+		if n.IsReachable() {
+			reachable++
+			if s := n.LastBlockNumberSample; s != nil && float64(s.BlockNumber) > maxHeight {
+				maxHeight = float64(s.BlockNumber)
+			}
+		}
+	}
+	var height, lag, peers, pending, queued, up, stuck, lastReach []sample
+	byEndpoint := map[string]string{}
+	for _, n := range nodes {
+		l := labels("node", n.ShortName, "address", n.RPCAddress)
+		byEndpoint[n.RPCAddress] = n.ShortName
+		up = append(up, sample{l, boolValue(n.IsReachable())})
+		if !time.Time(n.LastReach).IsZero() {
+			lastReach = append(lastReach, sample{l, float64(time.Time(n.LastReach).Unix())})
+		}
+		if !n.IsReachable() {
+			continue
+		}
+		stuck = append(stuck, sample{l, boolValue(n.IsStuck())})
+		peers = append(peers, sample{l, float64(len(n.Peers))})
+		if s := n.LastBlockNumberSample; s != nil {
+			height = append(height, sample{l, float64(s.BlockNumber)})
+			lag = append(lag, sample{l, maxHeight - float64(s.BlockNumber)})
+		}
+		if s := n.TxpoolStatus; s != nil {
+			pending = append(pending, sample{l, float64(s.Pending)})
+			queued = append(queued, sample{l, float64(s.Queued)})
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	writeFamily(buf, "toolsmith_network_nodes", "gauge", "Nodes in the network model.", []sample{{"", float64(len(nodes))}})
+	writeFamily(buf, "toolsmith_network_reachable_nodes", "gauge", "Nodes answering RPC calls.", []sample{{"", float64(reachable)}})
+	writeFamily(buf, "toolsmith_network_block_height_max", "gauge", "The highest block number of the reachable nodes.", []sample{{"", maxHeight}})
+	writeFamily(buf, "toolsmith_node_up", "gauge", "1 if the node answered the last probe.", up)
+	writeFamily(buf, "toolsmith_node_last_reach_timestamp_seconds", "gauge", "When the node last answered.", lastReach)
+	writeFamily(buf, "toolsmith_node_stuck", "gauge", "1 if the node has not made block progress within the threshold.", stuck)
+	writeFamily(buf, "toolsmith_node_block_height", "gauge", "The latest block number of the node.", height)
+	writeFamily(buf, "toolsmith_node_block_lag", "gauge", "Blocks behind the highest node of the network.", lag)
+	writeFamily(buf, "toolsmith_node_peers", "gauge", "Peers the node reports.", peers)
+	writeFamily(buf, "toolsmith_node_txpool_pending", "gauge", "Pending transactions in the node's pool.", pending)
+	writeFamily(buf, "toolsmith_node_txpool_queued", "gauge", "Queued transactions in the node's pool.", queued)
+
+	if fr := lhh.rpcClient.LastFees(); fr != nil {
+		l := labels("node", fr.Node.ShortName)
+		head := fr.Blocks[0]
+		if fr.NextBaseFee != nil {
+			baseFee, _ := strconv.ParseFloat(fr.NextBaseFee.Gwei(), 64)
+			writeFamily(buf, "toolsmith_chain_base_fee_gwei", "gauge", "The base fee of the next block.", []sample{{l, baseFee}})
+		}
+		writeFamily(buf, "toolsmith_chain_gas_used_ratio", "gauge", "The share of the gas limit used by the latest block.", []sample{{l, head.GasUsedRatio}})
+		writeFamily(buf, "toolsmith_chain_full_blocks", "gauge", "The latest blocks using nearly all of the gas limit, in a row.", []sample{{l, float64(fr.FullStreak)}})
+		writeFamily(buf, "toolsmith_chain_empty_blocks_ratio", "gauge", "The share of the empty blocks among the latest ones read.", []sample{{l, fr.EmptyPercent() / 100}})
+	}
+
+	stats := lhh.rpcClient.EndpointStats()
+	endpoints := make([]string, 0, len(stats))
+	for e := range stats {
+		endpoints = append(endpoints, e)
+	}
+	sort.Strings(endpoints)
+	var latencySum, latencyCount, lastLatency, failures, rpcErrors []sample
+	for _, e := range endpoints {
+		st := stats[e]
+		l := labels("endpoint", e, "node", byEndpoint[e])
+		latencySum = append(latencySum, sample{l, st.Latency.Seconds()})
+		latencyCount = append(latencyCount, sample{l, float64(st.Requests)})
+		lastLatency = append(lastLatency, sample{l, st.LastLatency.Seconds()})
+		failures = append(failures, sample{l, float64(st.Failures)})
+		rpcErrors = append(rpcErrors, sample{l, float64(st.RPCErrors)})
+	}
+	if len(endpoints) > 0 {
+		fmt.Fprintf(buf, "# HELP toolsmith_rpc_duration_seconds RPC round trips to the endpoint, a batch counting once.\n# TYPE toolsmith_rpc_duration_seconds summary\n")
+		writeSamples(buf, "toolsmith_rpc_duration_seconds_sum", latencySum)
+		writeSamples(buf, "toolsmith_rpc_duration_seconds_count", latencyCount)
+	}
+	writeFamily(buf, "toolsmith_rpc_last_duration_seconds", "gauge", "The latest RPC round trip to the endpoint.", lastLatency)
+	writeFamily(buf, "toolsmith_rpc_failures_total", "counter", "RPC round trips failed in the transport.", failures)
+	writeFamily(buf, "toolsmith_rpc_errors_total", "counter", "RPC calls answered with a JSON-RPC error.", rpcErrors)
+
+	writeFamily(buf, "toolsmith_watchdog_running", "gauge", "1 if the watchdog has been started.", []sample{{"", boolValue(lhh.watchdog != nil)}})
+	if lhh.watchdog != nil {
+		status := lhh.watchdog.GetStatus()
+		var states []sample
+		for _, s := range watchdog.StateNames {
+			states = append(states, sample{labels("state", s), boolValue(status.Main() == s)})
+		}
+		writeFamily(buf, "toolsmith_watchdog_state", "gauge", "The state of the watchdog.", states)
+		writeFamily(buf, "toolsmith_watchdog_severity", "gauge", "The severity of the current issue: 0 none, 1 amber, 2 red, 3 fork.", []sample{{"", float64(status.Rank())}})
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write(buf.Bytes())
+}
@@ -0,0 +1,72 @@
+package httphandler
+
+import (
+	"errors"
+	"github.com/san-lab/toolsmith/client"
+	"net/http"
+	"net/url"
+)
+
+//The replacement of a stuck transaction: the proposals to cancel or to resubmit it, the sending through a chosen node,
+//and the page following the replacement until it is mined
+
+type replacePageData struct {
+	Node     *client.Node
+	Resubmit *client.Replacement
+	Cancel   *client.Replacement
+}
+
+//Those which could be put together
+func (p *replacePageData) Proposals() []*client.Replacement {
+	var list []*client.Replacement
+	for _, rp := range []*client.Replacement{p.Resubmit, p.Cancel} {
+		if rp != nil {
+			list = append(list, rp)
+		}
+	}
+	return list
+}
+
+func (lhh *LilHttpHandler) replacePage(r *http.Request) (*replacePageData, error) {
+	page := &replacePageData{}
+	node, err := lhh.rpcClient.ExplorerNode(r.FormValue(nodeparamname))
+	if err != nil {
+		return page, err
+	}
+	page.Node = node
+	if page.Resubmit, err = lhh.rpcClient.ProposeReplacement(node, r.FormValue(hashparamname), false); err != nil {
+		return page, err
+	}
+	page.Cancel, err = lhh.rpcClient.ProposeReplacement(node, r.FormValue(hashparamname), true)
+	return page, err
+}
+
+//Sends the replacement and the browser to the page following it. Only by POST, so that the password
+//stays out of the URL, the logs and the browser history
+func (lhh *LilHttpHandler) replaceSend(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodPost {
+		return errors.New("the replacement is only sent by POST")
+	}
+	node, err := lhh.rpcClient.ExplorerNode(r.FormValue(nodeparamname))
+	if err != nil {
+		return err
+	}
+	via, err := lhh.rpcClient.ExplorerNode(r.FormValue(viaparamname))
+	if err != nil {
+		return err
+	}
+	rp, err := lhh.rpcClient.ProposeReplacement(node, r.FormValue(hashparamname), r.FormValue(cancelparamname) == "true")
+	if err != nil {
+		return err
+	}
+	if err = rp.SetFees(r.FormValue(gaspriceparamname), r.FormValue(maxfeeparamname), r.FormValue(tipparamname)); err != nil {
+		return err
+	}
+	hash, err := lhh.rpcClient.SendReplacement(via, rp, r.PostFormValue(passwordparamname))
+	if err != nil {
+		return err
+	}
+	q := url.Values{nodeparamname: {string(via.ID)}, hashparamname: {rp.Original.Hash}, replacementparamname: {hash}}
+	http.Redirect(w, r, "/"+replacement+"?"+q.Encode(), http.StatusSeeOther)
+	return nil
+}
@@ -0,0 +1,20 @@
+package httphandler
+
+import (
+	"github.com/san-lab/toolsmith/client"
+	"github.com/san-lab/toolsmith/templates"
+)
+
+//The analysis of a txpool_content call to a node's url, the raw page if the node is not in the model
+func (lhh *LilHttpHandler) txpoolResult(eNode string, callData *client.CallData) (string, interface{}, error) {
+	content, ok := callData.ParsedResult.(*client.TxpoolContent)
+	node, found := lhh.rpcClient.NetModel().FindNode(eNode)
+	if !ok || !callData.Parsed || !found {
+		return templates.Raw, callData, nil
+	}
+	report, err := lhh.rpcClient.AnalyzeTxpool(node, content)
+	if report == nil {
+		return templates.Raw, callData, err
+	}
+	return templates.Txpool, report, err
+}
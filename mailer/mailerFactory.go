@@ -47,6 +47,12 @@ func (m *Mailer) LoadTemplate() bool {
 // {.WachdogAddress}
 // {.UnreachableNodes}
 // {.StuckNodes}
+// {.InactiveSigners}
+// {.SignersDisagreeOn}
+// {.Validators}
+// {.Branches}
+// {.DeepReorgs}
+// {.Islands}
 //
 func (m *Mailer) RenderAlert(data interface{}) string {
 	if !m.templateLoaded {
@@ -4,7 +4,10 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"github.com/san-lab/toolsmith/abi"
+	"github.com/san-lab/toolsmith/client"
 	"github.com/san-lab/toolsmith/httphandler"
+	"github.com/san-lab/toolsmith/store"
 	"log"
 	"net/http"
 	"os"
@@ -18,13 +21,19 @@ import (
 //TODO: Take care of the favicon.ico location
 func main() {
 
-	ethRPCAddress := flag.String("ethRPCAddress", "localhost:8545", "default RPC access point")
+	ethRPCAddress := flag.String("ethRPCAddress", "localhost:8545", "default RPC access point, host:port or ipc:///path/to/geth.ipc")
 	httpPortF := flag.String("httpPort", "8090", "http port")
 	mockMode := flag.Bool("mockMode", false, "should mock http RPC client")
 	dumpRPC := flag.Bool("dumpRPC", false, "should dump RPC responses to files")
 	startWatchdog := flag.Bool("startWatchdog", false, "should a watchdog  be started")
 	withBasicAuth := flag.Bool("withAuth", true, "should Basic Authentication be enabled")
 	httpsPortF := flag.Int("httpsPort", 0, "https port. tls not started if not provided. requires server.crt & server.key")
+	wsPort := flag.String("wsPort", "", "websocket port of the nodes. if provided, new heads are followed over websocket subscriptions")
+	wsPendingTxs := flag.Bool("wsPendingTxs", false, "should the websocket subscriptions also follow the new pending transactions")
+	rpcConfig := flag.String("rpcConfig", client.DefaultEndpointsFile, "json file with per-endpoint scheme, credentials, headers and TLS settings")
+	storeDir := flag.String("storeDir", store.DefaultDir, "directory where the samples and the watchdog incidents are kept over restarts, none kept if empty")
+	abiFile := flag.String("abiFile", abi.DefaultRegistryFile, "json file where the uploaded contract ABIs are kept, none kept if empty")
+	discoveryWorkers := flag.Int("discoveryWorkers", client.DefaultDiscoveryWorkers, "number of nodes probed in parallel by discovery and rescans")
 	flag.Parse()
 
 	c := httphandler.Config{}
@@ -35,9 +44,20 @@ func main() {
 	c.DumpRPC = *dumpRPC
 	c.StartWatchdog = *startWatchdog
 	c.BasicAuth = *withBasicAuth
+	c.DiscoveryWorkers = *discoveryWorkers
+	c.WSPort = *wsPort
+	c.EndpointsFile = *rpcConfig
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "rpcConfig" {
+			c.EndpointsFileSet = true
+		}
+	})
+	c.WSPendingTxs = *wsPendingTxs
+	c.StoreDir = *storeDir
+	c.ABIFile = *abiFile
 	fmt.Println("Here")
 
-	interruptChan := make(chan os.Signal)
+	interruptChan := make(chan os.Signal, 1)
 	wg := &sync.WaitGroup{}
 	signal.Notify(interruptChan, os.Interrupt)
 	ctx := context.Background()
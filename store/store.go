@@ -0,0 +1,390 @@
+package store
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+)
+
+//An embedded append-only store, so that the samples and the events survive a restart. Every stream is a file of
+//JSON lines in the store directory, a record per line in the order of appending. The compaction rewrites a file
+//without the records older than the retention, and without the oldest ones if the file has outgrown its size limit.
+//A line cut short by a crash is skipped on reading and dropped by the next compaction
+
+const configFile = "store.config.json"
+const DefaultDir = "data"
+const defaultRetention = 7 * 24 * time.Hour
+const defaultCompactInterval = time.Hour
+const defaultMaxFileSize = 64 << 20
+const maxLineSize = 1 << 20
+
+type Config struct {
+	Retention       time.Duration //records older than this are dropped, 0 to keep them all
+	CompactInterval time.Duration
+	MaxFileSize     int64 //bytes per stream, 0 for no limit
+}
+
+//The durations are written as in "168h0m0s"
+func (c Config) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Retention       string
+		CompactInterval string
+		MaxFileSize     int64
+	}{c.Retention.String(), c.CompactInterval.String(), c.MaxFileSize})
+}
+
+//The durations are read as in "168h", or as the nanoseconds of the older files. The settings missing are left as they are
+func (c *Config) UnmarshalJSON(raw []byte) error {
+	var cj struct {
+		Retention       json.RawMessage
+		CompactInterval json.RawMessage
+		MaxFileSize     *int64
+	}
+	if err := json.Unmarshal(raw, &cj); err != nil {
+		return err
+	}
+	if err := readDuration(cj.Retention, &c.Retention); err != nil {
+		return err
+	}
+	if err := readDuration(cj.CompactInterval, &c.CompactInterval); err != nil {
+		return err
+	}
+	if cj.MaxFileSize != nil {
+		c.MaxFileSize = *cj.MaxFileSize
+	}
+	return nil
+}
+
+func readDuration(raw json.RawMessage, d *time.Duration) error {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil
+	}
+	var s string
+	if json.Unmarshal(raw, &s) != nil {
+		return json.Unmarshal(raw, (*int64)(d))
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+//A line of a stream file
+type record struct {
+	T time.Time       `json:"t"`
+	D json.RawMessage `json:"d"`
+}
+
+type StreamStats struct {
+	Name    string
+	Size    int64
+	Records int
+	Oldest  time.Time
+	Newest  time.Time
+}
+
+type Store struct {
+	dir    string
+	config Config
+	files  map[string]*os.File
+	sizes  map[string]int64
+	mx     sync.Mutex //guards the files; the readers open the stream files on their own
+}
+
+var streamName = regexp.MustCompile("^[a-z0-9_]+$")
+
+//Opens (creating if needed) the store in the directory. The settings are read from store.config.json
+func Open(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	s := &Store{dir: dir, files: map[string]*os.File{}, sizes: map[string]int64{}}
+	s.config = Config{Retention: defaultRetention, CompactInterval: defaultCompactInterval, MaxFileSize: defaultMaxFileSize}
+	s.LoadConfig()
+	if s.config.CompactInterval <= 0 {
+		s.config.CompactInterval = defaultCompactInterval
+	}
+	return s, nil
+}
+
+//Compacts the streams every CompactInterval, until the context is done
+func (s *Store) Start(ctx context.Context) {
+	wg, _ := ctx.Value("WaitGroup").(*sync.WaitGroup)
+	if wg != nil {
+		wg.Add(1)
+	}
+	go func() {
+		if wg != nil {
+			defer wg.Done()
+		}
+		s.Compact()
+		ticker := time.NewTicker(s.config.CompactInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				s.Close()
+				return
+			case <-ticker.C:
+				s.Compact()
+			}
+		}
+	}()
+}
+
+func (s *Store) GetConfig() Config {
+	return s.config
+}
+
+func (s *Store) path(stream string) string {
+	return filepath.Join(s.dir, stream+".jsonl")
+}
+
+//Appends the value as a record of the stream, stamped with the time
+func (s *Store) Append(stream string, t time.Time, v interface{}) error {
+	if !streamName.MatchString(stream) {
+		return errors.New("invalid stream name: " + stream)
+	}
+	d, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	line, err := json.Marshal(record{t, d})
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	f, err := s.file(stream)
+	if err != nil {
+		return err
+	}
+	n, err := f.Write(line)
+	s.sizes[stream] += int64(n)
+	if err != nil {
+		return err
+	}
+	if s.config.MaxFileSize > 0 && s.sizes[stream] > s.config.MaxFileSize {
+		return s.compact(stream)
+	}
+	return nil
+}
+
+//The open file of the stream. The caller holds the mx
+func (s *Store) file(stream string) (*os.File, error) {
+	if f, ok := s.files[stream]; ok {
+		return f, nil
+	}
+	f, err := os.OpenFile(s.path(stream), os.O_CREATE|os.O_APPEND|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	size := fi.Size()
+	//A line cut short must not swallow the next record
+	last := make([]byte, 1)
+	if _, err := f.ReadAt(last, size-1); err == nil && last[0] != '\n' {
+		n, _ := f.Write([]byte{'\n'})
+		size += int64(n)
+	}
+	s.files[stream] = f
+	s.sizes[stream] = size
+	return f, nil
+}
+
+//Calls back with the records of the stream not older than since, the oldest first. A missing stream has no records
+func (s *Store) Read(stream string, since time.Time, each func(t time.Time, data json.RawMessage)) error {
+	if !streamName.MatchString(stream) {
+		return errors.New("invalid stream name: " + stream)
+	}
+	f, err := os.Open(s.path(stream))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return scan(f, func(r record) {
+		if !r.T.Before(since) {
+			each(r.T, r.D)
+		}
+	})
+}
+
+func scan(f *os.File, each func(r record)) error {
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), maxLineSize)
+	for sc.Scan() {
+		var r record
+		if err := json.Unmarshal(sc.Bytes(), &r); err != nil {
+			log.Println("Skipping a broken record in", f.Name(), err)
+			continue
+		}
+		each(r)
+	}
+	return sc.Err()
+}
+
+//The streams in the store directory
+func (s *Store) Streams() []string {
+	matches, err := filepath.Glob(filepath.Join(s.dir, "*.jsonl"))
+	if err != nil {
+		log.Println(err)
+	}
+	var names []string
+	for _, m := range matches {
+		name := filepath.Base(m)
+		names = append(names, name[:len(name)-len(".jsonl")])
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (s *Store) Stats() []StreamStats {
+	var stats []StreamStats
+	for _, name := range s.Streams() {
+		st := StreamStats{Name: name}
+		if fi, err := os.Stat(s.path(name)); err == nil {
+			st.Size = fi.Size()
+		}
+		err := s.Read(name, time.Time{}, func(t time.Time, _ json.RawMessage) {
+			if st.Records == 0 || t.Before(st.Oldest) {
+				st.Oldest = t
+			}
+			if t.After(st.Newest) {
+				st.Newest = t
+			}
+			st.Records++
+		})
+		if err != nil {
+			log.Println(err)
+		}
+		stats = append(stats, st)
+	}
+	return stats
+}
+
+//Compacts all the streams
+func (s *Store) Compact() error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	var lastErr error
+	for _, name := range s.Streams() {
+		if err := s.compact(name); err != nil {
+			log.Println(err)
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+//Rewrites the stream without the expired records, keeping the newest ones within three quarters of the size limit,
+//so that an outgrown file is not rewritten on every append. The caller holds the mx
+func (s *Store) compact(stream string) error {
+	var cutoff time.Time
+	if s.config.Retention > 0 {
+		cutoff = time.Now().Add(-s.config.Retention)
+	}
+	f, err := os.Open(s.path(stream))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var lines [][]byte
+	err = scan(f, func(r record) {
+		if r.T.Before(cutoff) {
+			return
+		}
+		line, err := json.Marshal(r)
+		if err == nil {
+			lines = append(lines, append(line, '\n'))
+		}
+	})
+	f.Close()
+	if err != nil {
+		return err
+	}
+	if s.config.MaxFileSize > 0 {
+		var size int64
+		i := len(lines)
+		for i > 0 && size+int64(len(lines[i-1])) <= s.config.MaxFileSize*3/4 {
+			i--
+			size += int64(len(lines[i]))
+		}
+		lines = lines[i:]
+	}
+	tmp, err := ioutil.TempFile(s.dir, stream+".compacting")
+	if err != nil {
+		return err
+	}
+	var size int64
+	for _, l := range lines {
+		n, err := tmp.Write(l)
+		size += int64(n)
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+			return err
+		}
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if f, ok := s.files[stream]; ok {
+		f.Close()
+		delete(s.files, stream)
+	}
+	if err := os.Rename(tmp.Name(), s.path(stream)); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	s.sizes[stream] = size
+	return nil
+}
+
+//Closes the stream files. Appending reopens them
+func (s *Store) Close() {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	for name, f := range s.files {
+		if err := f.Close(); err != nil {
+			log.Println(err)
+		}
+		delete(s.files, name)
+	}
+}
+
+func (s *Store) LoadConfig() error {
+	buff, err := ioutil.ReadFile("./" + configFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Println(err)
+		}
+		return err
+	}
+	err = json.Unmarshal(buff, &s.config)
+	if err != nil {
+		log.Println(err)
+	}
+	return err
+}
@@ -0,0 +1,181 @@
+package store
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+type sample struct {
+	N int
+}
+
+func openTest(t *testing.T, dir string) *Store {
+	s, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.Close)
+	return s
+}
+
+//The numbers of the samples of the stream, the oldest first
+func readAll(t *testing.T, s *Store, stream string, since time.Time) []int {
+	var ns []int
+	err := s.Read(stream, since, func(_ time.Time, data json.RawMessage) {
+		var v sample
+		if err := json.Unmarshal(data, &v); err != nil {
+			t.Error(err)
+		}
+		ns = append(ns, v.N)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ns
+}
+
+func checkNumbers(t *testing.T, got []int, want ...int) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got the samples %v, expected %v", got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Fatalf("got the samples %v, expected %v", got, want)
+		}
+	}
+}
+
+func TestReopen(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Now().Add(-time.Minute).Round(0)
+	s := openTest(t, dir)
+	for i := 0; i < 3; i++ {
+		if err := s.Append("samples", start.Add(time.Duration(i)*time.Second), sample{i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.Close()
+	s = openTest(t, dir)
+	if err := s.Append("samples", start.Add(3*time.Second), sample{3}); err != nil {
+		t.Fatal(err)
+	}
+	checkNumbers(t, readAll(t, s, "samples", time.Time{}), 0, 1, 2, 3)
+	//The records are read since the time given, that one included
+	checkNumbers(t, readAll(t, s, "samples", start.Add(2*time.Second)), 2, 3)
+	var oldest time.Time
+	s.Read("samples", time.Time{}, func(ts time.Time, _ json.RawMessage) {
+		if oldest.IsZero() {
+			oldest = ts
+		}
+	})
+	if !oldest.Equal(start) {
+		t.Errorf("the time %v read back as %v", start, oldest)
+	}
+	if streams := s.Streams(); len(streams) != 1 || streams[0] != "samples" {
+		t.Errorf("got the streams %v", streams)
+	}
+	if err := s.Append("no such stream", start, sample{}); err == nil {
+		t.Error("appended to an invalid stream")
+	}
+}
+
+func TestTornLine(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	s := openTest(t, dir)
+	s.Append("samples", now, sample{1})
+	s.Close()
+	//A crash in the middle of a record
+	f, err := os.OpenFile(s.path("samples"), os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte(`{"t":"2021-01-01T00:00:00Z","d":{"N":`))
+	f.Close()
+	s = openTest(t, dir)
+	if err := s.Append("samples", now, sample{2}); err != nil {
+		t.Fatal(err)
+	}
+	checkNumbers(t, readAll(t, s, "samples", time.Time{}), 1, 2)
+	if err := s.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	raw, err := ioutil.ReadFile(s.path("samples"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSuffix(string(raw), "\n"), "\n"); len(lines) != 2 {
+		t.Errorf("the compaction left the lines %q", lines)
+	}
+	checkNumbers(t, readAll(t, s, "samples", time.Time{}), 1, 2)
+}
+
+func TestCompactSize(t *testing.T) {
+	dir := t.TempDir()
+	s := openTest(t, dir)
+	s.config = Config{MaxFileSize: 1000}
+	now := time.Now()
+	var appended []int
+	compacted := false
+	for i := 0; i < 30; i++ {
+		before := s.sizes["samples"]
+		if err := s.Append("samples", now.Add(time.Duration(i)*time.Second), sample{i}); err != nil {
+			t.Fatal(err)
+		}
+		appended = append(appended, i)
+		if s.sizes["samples"] < before {
+			compacted = true
+			//Down to three quarters of the limit, not to the limit itself
+			if s.sizes["samples"] > s.config.MaxFileSize*3/4 {
+				t.Errorf("compacted to %d bytes, above three quarters of %d", s.sizes["samples"], s.config.MaxFileSize)
+			}
+		}
+	}
+	if !compacted {
+		t.Fatal("the stream was not compacted")
+	}
+	fi, err := os.Stat(s.path("samples"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Size() > s.config.MaxFileSize {
+		t.Errorf("the file of %d bytes outgrew the limit of %d", fi.Size(), s.config.MaxFileSize)
+	}
+	if s.sizes["samples"] != fi.Size() {
+		t.Errorf("the size kept %d differs from the file size %d", s.sizes["samples"], fi.Size())
+	}
+	got := readAll(t, s, "samples", time.Time{})
+	if len(got) == 0 || len(got) == len(appended) {
+		t.Fatalf("kept %d of the %d samples", len(got), len(appended))
+	}
+	//the newest ones
+	checkNumbers(t, got, appended[len(appended)-len(got):]...)
+}
+
+func TestRetention(t *testing.T) {
+	dir := t.TempDir()
+	s := openTest(t, dir)
+	s.config = Config{Retention: time.Hour}
+	now := time.Now()
+	for i, age := range []time.Duration{2 * time.Hour, time.Hour + time.Minute, time.Hour - time.Minute, time.Minute} {
+		if err := s.Append("samples", now.Add(-age), sample{i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	checkNumbers(t, readAll(t, s, "samples", time.Time{}), 2, 3)
+	//Kept all with no retention
+	s.config = Config{}
+	s.Append("samples", now.Add(-100*24*time.Hour), sample{4})
+	if err := s.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	checkNumbers(t, readAll(t, s, "samples", time.Time{}), 2, 3, 4)
+}
@@ -6,10 +6,12 @@ import (
 	"io/ioutil"
 	"log"
 	"strings"
+	"sync"
 )
 
 type Renderer struct {
 	templates *template.Template
+	mx        sync.RWMutex //templates may be reloaded while being rendered
 }
 
 func NewRenderer() *Renderer {
@@ -25,6 +27,27 @@ const Peers = "peers"
 const ListMap = "listMap"
 const TxpoolStatus = "txpoolStatus"
 const BlockNumber = "blockNumber"
+const Clique = "clique"
+const Validators = "validators"
+const CliqueVotes = "cliquevotes"
+const Forks = "forks"
+const Reorgs = "reorgs"
+const Partitions = "partitions"
+const Charts = "charts"
+const History = "history"
+const Explorer = "explorer"
+const Block = "block"
+const Tx = "tx"
+const Receipt = "receipt"
+const Account = "account"
+const ABIRegistry = "abi"
+const Contract = "contract"
+const Decoded = "decoded"
+const Txpool = "txpool"
+const Propagation = "propagation"
+const Replace = "replace"
+const Replacement = "replacement"
+const Fees = "fees"
 
 //Taken out of the constructor with the idae of forced template reloading
 func (r *Renderer) LoadTemplates() {
@@ -39,14 +62,20 @@ func (r *Renderer) LoadTemplates() {
 			allFiles = append(allFiles, "./templates/"+filename)
 		}
 	}
-	r.templates, err = template.ParseFiles(allFiles...) //parses all .tmpl files in the 'templates' folder
+	tmpls, err := template.ParseFiles(allFiles...) //parses all .tmpl files in the 'templates' folder
 	if err != nil {
 		log.Println(err)
 	}
+	r.mx.Lock()
+	r.templates = tmpls
+	r.mx.Unlock()
 }
 
 func (r *Renderer) RenderResponse(w io.Writer, data RenderData) error {
-	err := r.templates.ExecuteTemplate(w, data.TemplateName, data)
+	r.mx.RLock()
+	tmpls := r.templates
+	r.mx.RUnlock()
+	err := tmpls.ExecuteTemplate(w, data.TemplateName, data)
 	if err != nil {
 		log.Println(err)
 	}
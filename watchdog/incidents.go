@@ -0,0 +1,92 @@
+package watchdog
+
+import (
+	"encoding/json"
+	"github.com/san-lab/toolsmith/client"
+	"github.com/san-lab/toolsmith/store"
+	"log"
+	"time"
+)
+
+//The incidents the watchdog has raised, kept in the store so that they outlive a restart.
+//An incident still open when the watchdog stopped is taken up again, to be closed with the usual "back to normal" email
+
+const incidentsStream = "incidents"
+
+const incidentOpened = "opened"
+const incidentClosed = "closed"
+const incidentSuperseded = "superseded"     //a higher severity has raised a new issue
+const incidentAcknowledged = "acknowledged" //by the operator, setting the status OK
+
+//What is written to the store
+type incidentEvent struct {
+	Issue    string
+	Event    string
+	Severity string   `json:",omitempty"`
+	Details  []string `json:",omitempty"`
+}
+
+type Incident struct {
+	Issue    string
+	Severity string
+	Details  []string
+	Opened   client.MyTime
+	Closed   client.MyTime
+	IsOpen   bool
+	Outcome  string //closed, superseded or acknowledged
+}
+
+//The incidents kept in the store, the latest first
+func Incidents(st *store.Store) []Incident {
+	if st == nil {
+		return nil
+	}
+	var incidents []Incident
+	latest := map[string]int{}
+	err := st.Read(incidentsStream, time.Time{}, func(t time.Time, data json.RawMessage) {
+		var ev incidentEvent
+		if json.Unmarshal(data, &ev) != nil {
+			return
+		}
+		if ev.Event == incidentOpened {
+			latest[ev.Issue] = len(incidents)
+			incidents = append(incidents, Incident{Issue: ev.Issue, Severity: ev.Severity, Details: ev.Details, Opened: client.MyTime(t), IsOpen: true})
+			return
+		}
+		if i, ok := latest[ev.Issue]; ok && incidents[i].IsOpen {
+			incidents[i].IsOpen = false
+			incidents[i].Closed = client.MyTime(t)
+			incidents[i].Outcome = ev.Event
+		}
+	})
+	if err != nil {
+		log.Println(err)
+	}
+	for i, j := 0, len(incidents)-1; i < j; i, j = i+1, j-1 {
+		incidents[i], incidents[j] = incidents[j], incidents[i]
+	}
+	return incidents
+}
+
+//The caller holds the stateMx
+func (w *Watchdog) recordIncident(ev incidentEvent) {
+	st := w.rpcClient.Store()
+	if st == nil {
+		return
+	}
+	if err := st.Append(incidentsStream, time.Now(), ev); err != nil {
+		log.Println(err)
+	}
+}
+
+//Takes up the latest incident if it was still open when the watchdog stopped.
+//An acknowledged incident is not open any more, so no "back to normal" email is sent for it
+func (w *Watchdog) resumeIncident() {
+	incidents := Incidents(w.rpcClient.Store())
+	if len(incidents) == 0 || !incidents[0].IsOpen || incidents[0].Outcome == incidentAcknowledged {
+		return
+	}
+	w.currentIssue = incidents[0].Issue
+	w.state = State{main: notified, severity: severity(incidents[0].Severity)}
+	log.Println("Resuming the open issue", w.currentIssue, incidents[0].Severity)
+}
@@ -3,11 +3,14 @@ package watchdog
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"github.com/san-lab/toolsmith/client"
 	"github.com/san-lab/toolsmith/mailer"
 	"io/ioutil"
 	"log"
+	"math/big"
 	"regexp"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -25,12 +28,41 @@ type severity string
 
 var sevAmber severity = "AMBER"
 var sevRed severity = "RED"
+var sevFork severity = "FORK" //the nodes follow different chains
+
+//The order in which the severities escalate
+func (sev severity) rank() int {
+	switch sev {
+	case sevAmber:
+		return 1
+	case sevRed:
+		return 2
+	case sevFork:
+		return 3
+	}
+	return 0
+}
 
 var okState = "OK"
 var detected = "DETECTED"
 var notified = "NOTIFIED"
 var stateReset = "RESET"
 
+var StateNames = []string{okState, detected, notified, stateReset}
+
+func (s State) Main() string {
+	return s.main
+}
+
+func (s State) Severity() string {
+	return string(s.severity)
+}
+
+//0 when there is no issue, up to 3 for a fork
+func (s State) Rank() int {
+	return s.severity.rank()
+}
+
 func (s *State) isOK() bool {
 	return s.main == okState
 }
@@ -50,12 +82,17 @@ type Watchdog struct {
 	ticker       *time.Ticker
 	exitChan     chan interface{}
 	wg           *sync.WaitGroup
+	lastProbe    time.Time
+	stateMx      sync.Mutex //guards state, currentIssue and config against the http handlers
 }
 
 type Config struct {
 	Recipients     map[string]bool
 	ProbeInterval  time.Duration
 	BlockThreshold time.Duration
+	ReorgDepth     int     //alert on the reorgs deeper than this, 0 for no alerts
+	FullBlocks     int     //alert when this many latest blocks are all full, 0 for no alerts
+	MaxBaseFee     float64 //alert when the base fee goes over this many gwei, 0 for no alerts
 }
 
 var started uint32
@@ -78,9 +115,9 @@ func StartWatchdog(rpcClient *client.Client, ctx context.Context) *Watchdog {
 		instance.config.ProbeInterval = defaultProbeInterval
 	}
 	if instance.config.BlockThreshold == 0 {
-		instance.config.BlockThreshold = client.Threshold
+		instance.config.BlockThreshold = client.GetThreshold()
 	} else {
-		client.Threshold = instance.config.BlockThreshold
+		client.SetThreshold(instance.config.BlockThreshold)
 	}
 
 	instance.execContext = ctx
@@ -88,6 +125,7 @@ func StartWatchdog(rpcClient *client.Client, ctx context.Context) *Watchdog {
 	instance.wg, _ = ctx.Value("WaitGroup").(*sync.WaitGroup)
 	instance.wg.Add(1)
 	instance.state = State{main: stateReset}
+	instance.resumeIncident()
 	go instance.run()
 	return instance
 }
@@ -115,6 +153,7 @@ var none = notificationType("none")
 //Decides if any action is needed (escalate/deescalate/none)
 //Also sets the new state of the watchdog
 //Only the detected->notified transition happens outside of this method
+//The caller has to hold the stateMx
 func (w *Watchdog) shouldNotify(s *State) notificationType {
 
 	if w.state.isNotified() && s.isOK() {
@@ -125,8 +164,8 @@ func (w *Watchdog) shouldNotify(s *State) notificationType {
 		w.state = *s
 		return escalate
 	}
-	if s.isDetected() && w.state.severity == sevAmber && s.severity == sevRed {
-		w.state.severity = sevRed
+	if s.isDetected() && !w.state.isOK() && w.state.main != stateReset && s.severity.rank() > w.state.severity.rank() {
+		w.state.severity = s.severity
 		return escalate
 	}
 	if w.state.main == stateReset && s.isOK() {
@@ -140,34 +179,120 @@ func (w *Watchdog) probe() {
 	defer mx.Unlock()
 	log.Println("Watching out!")
 	progress, unreach, stuck := w.rpcClient.HeartBeat()
+	cliqueReport := w.rpcClient.NetModel().CliqueReport()
+	cliqueOK := cliqueReport == nil || cliqueReport.IsOK()
+	bftReport := w.rpcClient.NetModel().BFTReport()
+	quorumLost := bftReport != nil && bftReport.QuorumLost()
+	forkReport := w.rpcClient.DetectForks()
+	graphReport := w.rpcClient.NetModel().AnalyzeGraph()
+	//The fees are tracked for the charts even with no alerts on them, and the chains older than London have none to track
+	var feeReport *client.FeeReport
+	node, err := w.rpcClient.FeeNode()
+	if err == nil {
+		feeReport, err = w.rpcClient.TrackFees(node)
+	}
+	if err != nil && (w.GetFullBlocks() > 0 || w.GetMaxBaseFee() > 0) {
+		log.Println(err)
+	}
+	//The mail is rendered and sent once the stateMx is released (the deferred calls run in reverse),
+	//so that the http handlers do not wait for the mail server
+	var notify func()
+	defer func() {
+		if notify != nil {
+			notify()
+		}
+	}()
+	w.stateMx.Lock()
+	defer w.stateMx.Unlock()
+	deepReorgs := []string{}
+	if w.config.ReorgDepth > 0 {
+		model := w.rpcClient.NetModel()
+		for _, r := range model.Reorgs() {
+			if !time.Time(r.Detected).After(w.lastProbe) {
+				break
+			}
+			if r.Depth > w.config.ReorgDepth {
+				deepReorgs = append(deepReorgs, fmt.Sprintf("%s: %v blocks, %v %s -> %v %s at %s", model.Nodes[r.Node].ShortName,
+					r.Depth, r.OldHead.Number, r.OldHead.Hash, r.NewHead.Number, r.NewHead.Hash, r.Detected))
+			}
+		}
+	}
+	w.lastProbe = time.Now()
+	fees := []string{}
+	if feeReport != nil {
+		if w.config.FullBlocks > 0 && feeReport.FullStreak >= w.config.FullBlocks {
+			fees = append(fees, fmt.Sprintf("the last %v blocks are full, %.0f%% of the gas limit used over the last %v blocks",
+				feeReport.FullStreak, feeReport.AvgGasUsed, len(feeReport.Blocks)))
+		}
+		if next := feeReport.NextBaseFee; w.config.MaxBaseFee > 0 && next != nil && gweiOver(next, w.config.MaxBaseFee) {
+			fees = append(fees, fmt.Sprintf("the base fee is %s gwei, over %v gwei", next.Gwei(), w.config.MaxBaseFee))
+		}
+	}
 
 	//Establish the new state
 	s := State{}
 	if progress {
-		if unreach > 0 || stuck > 0 {
+		if unreach > 0 || stuck > 0 || !cliqueOK {
 			s.main = detected
 			s.severity = sevAmber
 		} else {
 			s.main = okState
 		}
+		//Nodes disagreeing on the signers are on different chains
+		if !cliqueOK && len(cliqueReport.Disagreeing) > 0 {
+			s.severity = sevRed
+		}
+		//The blocks still coming are the last ones
+		if quorumLost {
+			s.main = detected
+			s.severity = sevRed
+		}
 	} else {
 		s.main = detected
 		s.severity = sevRed
 	}
+	//Islands of nodes not seeing each other, which may still be making progress on their own
+	if graphReport.IsSplit() && s.isOK() {
+		s.main = detected
+		s.severity = sevAmber
+	}
+	//A reorg is over by the time it is seen, so this one clears with the next probe
+	if len(deepReorgs) > 0 && s.isOK() {
+		s.main = detected
+		s.severity = sevAmber
+	}
+	//Congestion does not stop the network, so it does not raise the severity of another issue
+	if len(fees) > 0 && s.isOK() {
+		s.main = detected
+		s.severity = sevAmber
+	}
+	//Both halves of a partitioned network may well be making progress
+	if forkReport.IsForked() {
+		s.main = detected
+		s.severity = sevFork
+	}
 
 	notif := w.shouldNotify(&s)
 	if notif == deescalate {
-		message := mailer.GetMailer().RenderOver(w.currentIssue)
-		mailer.GetMailer().SendEmail(w.RecipientsAWSStyle(), "Issue: "+w.currentIssue+">> Blochchain network back to normal", message, "it is over")
+		issue, recipients := w.currentIssue, w.recipientsAWSStyle()
+		notify = func() {
+			message := mailer.GetMailer().RenderOver(issue)
+			mailer.GetMailer().SendEmail(recipients, "Issue: "+issue+">> Blochchain network back to normal", message, "it is over")
+		}
+		w.recordIncident(incidentEvent{Issue: w.currentIssue, Event: incidentClosed})
 		w.currentIssue = ""
 	} else {
 		if notif == escalate {
+			if len(w.currentIssue) > 0 {
+				w.recordIncident(incidentEvent{Issue: w.currentIssue, Event: incidentSuperseded})
+			}
 			w.currentIssue = w.generateIssueID()
 
-			wAddress := w.rpcClient.LocalInfo.ClientIp
+			wAddress := w.rpcClient.LocalInfo().ClientIp
 			unr := []string{}
 			stk := []string{}
-			for _, n := range w.rpcClient.NetModel.Nodes {
+			model := w.rpcClient.NetModel()
+			for _, n := range model.Nodes {
 				if !n.IsReachable() {
 					unr = append(unr, n.ShortName)
 				}
@@ -175,18 +300,84 @@ func (w *Watchdog) probe() {
 					stk = append(stk, n.ShortName)
 				}
 			}
+			inactive := []string{}
+			disagreeing := []string{}
+			if cliqueReport != nil {
+				inactive = cliqueReport.Inactive
+				for _, id := range cliqueReport.Disagreeing {
+					if n, ok := model.Nodes[id]; ok {
+						disagreeing = append(disagreeing, n.ShortName)
+					}
+				}
+			}
+			validators := ""
+			if bftReport != nil && (quorumLost || len(bftReport.Offline) > 0) {
+				validators = fmt.Sprintf("%v of %v validators reachable, the quorum is %v", len(bftReport.Online), len(bftReport.Validators), bftReport.Quorum)
+			}
+			forks := []string{}
+			if forkReport.IsForked() {
+				for i, b := range forkReport.Branches {
+					names := []string{}
+					for _, id := range b.Nodes {
+						if n, ok := model.Nodes[id]; ok {
+							names = append(names, n.ShortName)
+						}
+					}
+					desc := fmt.Sprintf("head %v: %s", b.Head, strings.Join(names, ", "))
+					if i > 0 {
+						desc += fmt.Sprintf(" (forked after block %v)", b.ForkPoint)
+					}
+					forks = append(forks, desc)
+				}
+			}
+			islands := []string{}
+			if graphReport.IsSplit() {
+				for _, c := range graphReport.Components[1:] {
+					names := []string{}
+					for _, id := range c {
+						if n, ok := model.Nodes[id]; ok {
+							names = append(names, n.ShortName)
+						}
+					}
+					islands = append(islands, strings.Join(names, ", "))
+				}
+			}
 			var data = struct {
-				IssueID          string
-				Severity         severity
-				WatchdogAddress  string
-				UnreachableNodes []string
-				StuckNodes       []string
+				IssueID           string
+				Severity          severity
+				WatchdogAddress   string
+				UnreachableNodes  []string
+				StuckNodes        []string
+				InactiveSigners   []string
+				SignersDisagreeOn []string
+				Validators        string
+				Branches          []string
+				DeepReorgs        []string
+				Islands           []string
+				Fees              []string
 			}{
-				w.currentIssue, s.severity, wAddress, unr, stk,
+				w.currentIssue, s.severity, wAddress, unr, stk, inactive, disagreeing, validators, forks, deepReorgs, islands, fees,
+			}
+			details := []string{}
+			for _, d := range []struct {
+				label string
+				items []string
+			}{{"Unreachable nodes", unr}, {"Stuck nodes", stk}, {"Inactive signers", inactive}, {"Signers disagree on", disagreeing},
+				{"Branches", forks}, {"Deep reorgs", deepReorgs}, {"Islands", islands}, {"Fees", fees}} {
+				if len(d.items) > 0 {
+					details = append(details, d.label+": "+strings.Join(d.items, "; "))
+				}
+			}
+			if len(validators) > 0 {
+				details = append(details, validators)
+			}
+			w.recordIncident(incidentEvent{Issue: w.currentIssue, Event: incidentOpened, Severity: string(s.severity), Details: details})
+			recipients := w.recipientsAWSStyle()
+			notify = func() {
+				mailer.GetMailer().LoadTemplate() //Debug line...
+				message := mailer.GetMailer().RenderAlert(data)
+				mailer.GetMailer().SendEmail(recipients, "Something wrong with Blockchain Net. Issue: "+data.IssueID, message, "alert!")
 			}
-			mailer.GetMailer().LoadTemplate() //Debug line...
-			message := mailer.GetMailer().RenderAlert(data)
-			mailer.GetMailer().SendEmail(w.RecipientsAWSStyle(), "Something wrong with Blockchain Net. Issue: "+w.currentIssue, message, "alert!")
 			w.state.main = notified
 		}
 	}
@@ -197,40 +388,117 @@ func (w *Watchdog) generateIssueID() string {
 	return time.Now().Format("020120060304")
 }
 
+//Acknowledges the current issue, if any: it is closed in the history and is not taken up again after a restart
 func (w *Watchdog) SetStatusOk() {
+	w.stateMx.Lock()
+	defer w.stateMx.Unlock()
+	if len(w.currentIssue) > 0 {
+		w.recordIncident(incidentEvent{Issue: w.currentIssue, Event: incidentAcknowledged})
+		w.currentIssue = ""
+	}
 	w.state.main = okState
 	w.state.severity = ""
 }
 
 func (w *Watchdog) GetStatus() State {
+	w.stateMx.Lock()
+	defer w.stateMx.Unlock()
 	return w.state
 }
 
 //in seconds
 func (w *Watchdog) SetInterval(interval int64) {
+	if interval <= 0 {
+		return
+	}
+	w.stateMx.Lock()
+	defer w.stateMx.Unlock()
 	w.config.ProbeInterval = time.Duration(interval) * time.Second
-	w.ticker = time.NewTicker(w.config.ProbeInterval)
+	w.ticker.Reset(w.config.ProbeInterval)
 }
 
 //in seconds
 func (w *Watchdog) GetInterval() int64 {
+	w.stateMx.Lock()
+	defer w.stateMx.Unlock()
 	return int64(w.config.ProbeInterval / time.Second)
 
 }
 
 //Set the max time (in seconds) for a new block to be mined/approved
 func (w *Watchdog) SetThreshold(interval int64) {
-	client.Threshold = time.Second * time.Duration(interval)
-	w.config.BlockThreshold = client.Threshold
+	w.stateMx.Lock()
+	defer w.stateMx.Unlock()
+	client.SetThreshold(time.Second * time.Duration(interval))
+	w.config.BlockThreshold = client.GetThreshold()
 }
 
 //Get the max time (in seconds) for a new block to be mined/approved
 func (w *Watchdog) GetThreshold() int64 {
-	return int64(client.Threshold / time.Second)
+	return int64(client.GetThreshold() / time.Second)
+}
+
+//Alert on the reorgs deeper than the given number of blocks, 0 to switch off
+func (w *Watchdog) SetReorgDepth(depth int) {
+	if depth < 0 {
+		return
+	}
+	w.stateMx.Lock()
+	defer w.stateMx.Unlock()
+	w.config.ReorgDepth = depth
+}
+
+func (w *Watchdog) GetReorgDepth() int {
+	w.stateMx.Lock()
+	defer w.stateMx.Unlock()
+	return w.config.ReorgDepth
+}
+
+//Alert when the given number of the latest blocks are all full, 0 to switch off
+func (w *Watchdog) SetFullBlocks(blocks int) {
+	if blocks < 0 {
+		return
+	}
+	w.stateMx.Lock()
+	defer w.stateMx.Unlock()
+	w.config.FullBlocks = blocks
+}
+
+func (w *Watchdog) GetFullBlocks() int {
+	w.stateMx.Lock()
+	defer w.stateMx.Unlock()
+	return w.config.FullBlocks
+}
+
+//Alert when the base fee goes over the given gwei, 0 to switch off
+func (w *Watchdog) SetMaxBaseFee(gwei float64) {
+	if gwei < 0 {
+		return
+	}
+	w.stateMx.Lock()
+	defer w.stateMx.Unlock()
+	w.config.MaxBaseFee = gwei
+}
+
+func (w *Watchdog) GetMaxBaseFee() float64 {
+	w.stateMx.Lock()
+	defer w.stateMx.Unlock()
+	return w.config.MaxBaseFee
+}
+
+func gweiOver(fee *client.BigHex, limit float64) bool {
+	wei, _ := new(big.Float).Mul(big.NewFloat(limit), big.NewFloat(1e9)).Int(nil)
+	return fee.Cmp(wei) > 0
 }
 
 //List active recipients in aws-sdk friendly format
 func (w *Watchdog) RecipientsAWSStyle() []*string {
+	w.stateMx.Lock()
+	defer w.stateMx.Unlock()
+	return w.recipientsAWSStyle()
+}
+
+func (w *Watchdog) recipientsAWSStyle() []*string {
 	var list []*string
 	for em, active := range w.config.Recipients {
 		if active {
@@ -242,13 +510,20 @@ func (w *Watchdog) RecipientsAWSStyle() []*string {
 }
 
 func (w *Watchdog) GetRecipients() map[string]bool {
-	rc := w.config.Recipients // is this defensive copying even necessary?
+	w.stateMx.Lock()
+	defer w.stateMx.Unlock()
+	rc := make(map[string]bool, len(w.config.Recipients)) // the probe and the handlers may both be at it
+	for em, active := range w.config.Recipients {
+		rc[em] = active
+	}
 	return rc
 }
 
 //If the email is on the list of recipients, set the active flag to false
 //returns if the email has been found on the list
 func (w *Watchdog) BlockRecipient(email string) bool {
+	w.stateMx.Lock()
+	defer w.stateMx.Unlock()
 	var ok bool
 	if _, ok = w.config.Recipients[email]; ok {
 		w.config.Recipients[email] = false
@@ -259,6 +534,8 @@ func (w *Watchdog) BlockRecipient(email string) bool {
 //If the email is on the list of recipients, it is removed from it
 //returns if the email has been found on the list
 func (w *Watchdog) RemoveRecipient(email string) bool {
+	w.stateMx.Lock()
+	defer w.stateMx.Unlock()
 	var ok bool
 	if _, ok = w.config.Recipients[email]; ok {
 		delete(w.config.Recipients, email)
@@ -268,6 +545,8 @@ func (w *Watchdog) RemoveRecipient(email string) bool {
 
 //Regexp-validates given email. If valid, adds to the recipients list. Returns validation result
 func (w *Watchdog) AddRecipient(email string) bool {
+	w.stateMx.Lock()
+	defer w.stateMx.Unlock()
 	if w.config.Recipients == nil {
 		w.config.Recipients = map[string]bool{}
 	}
@@ -295,7 +574,9 @@ func (w *Watchdog) LoadConfig() error {
 
 //Normally invoked only if context.cancel (aka ^C) stops the execution
 func (w *Watchdog) SaveConfig() {
+	w.stateMx.Lock()
 	bytes, err := json.Marshal(w.config)
+	w.stateMx.Unlock()
 	if err != nil {
 		log.Println(err)
 		return